@@ -0,0 +1,251 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+)
+
+// 关卡结构分析报告（不考虑战斗，所有连接点都视为可以通过）
+type AnalysisReport struct {
+	EndReachable         bool
+	MandatoryGates       []string         // 每条通往终点的路线都必须经过的连接点
+	ArticulationGates    []*GateShadow    // 挡住了部分区域的连接点（必经连接点也在其中）
+	UnreachableTreasures []string         // 无论如何都拿不到的宝物
+	MinYellowKeys        int              // 到达终点最少需要开的黄门数
+	MinBlueKeys          int              // 到达终点最少需要开的蓝门数
+	AreaDominators       map[int][]string // 区域ID -> 到达该区域必须经过的连接点
+}
+
+// 连接点及其身后的区域：不通过该连接点就到不了这些区域
+type GateShadow struct {
+	Gate  string
+	Areas []int
+}
+
+// 打印分析报告
+func (r *AnalysisReport) Print() {
+	fmt.Printf("关卡分析: 终点可达=%v, 必经连接点%d个, 割点连接点%d个, 拿不到的宝物%d个\n",
+		r.EndReachable, len(r.MandatoryGates), len(r.ArticulationGates), len(r.UnreachableTreasures))
+	if len(r.MandatoryGates) > 0 {
+		fmt.Printf("  必经: %s\n", strings.Join(r.MandatoryGates, ", "))
+	}
+	for _, shadow := range r.ArticulationGates {
+		fmt.Printf("  %s 挡住区域 %v\n", shadow.Gate, shadow.Areas)
+	}
+	if len(r.UnreachableTreasures) > 0 {
+		fmt.Printf("  拿不到: %s\n", strings.Join(r.UnreachableTreasures, ", "))
+	}
+	if r.EndReachable {
+		fmt.Printf("  到达终点最少需要: 黄钥匙%d把, 蓝钥匙%d把\n", r.MinYellowKeys, r.MinBlueKeys)
+	}
+}
+
+// 关卡分析器
+type graphAnalyzer struct {
+	graph     *Graph
+	links     map[int][]int // 区域ID -> 无需战斗即可直接到达的区域列表（楼梯等）
+	startArea int
+	endArea   int
+}
+
+func newGraphAnalyzer(g *Graph, startArea, endArea int) *graphAnalyzer {
+	a := &graphAnalyzer{
+		graph:     g,
+		links:     make(map[int][]int),
+		startArea: startArea,
+		endArea:   endArea,
+	}
+	for _, link := range g.Links {
+		a.links[link.From] = append(a.links[link.From], link.To)
+	}
+	return a
+}
+
+// 从startArea到endArea的每条路线都必须经过的连接点ID（不考虑战斗），到不了终点时返回nil
+func (g *Graph) MandatoryGateIDs(startArea, endArea int) []int {
+	a := newGraphAnalyzer(g, startArea, endArea)
+	if !a.reachableWithout(-1)[endArea] {
+		return nil
+	}
+	gateIDs := []int{}
+	for _, gate := range g.Gates {
+		if !a.reachableWithout(gate.ID)[endArea] {
+			gateIDs = append(gateIDs, gate.ID)
+		}
+	}
+	return gateIDs
+}
+
+// 分析关卡结构：必经连接点、割点连接点、拿不到的宝物和到达终点最少需要的钥匙
+func (g *Graph) Analyze() *AnalysisReport {
+	a := newGraphAnalyzer(g, g.StartArea, g.EndArea)
+
+	report := &AnalysisReport{
+		MandatoryGates:       []string{},
+		ArticulationGates:    []*GateShadow{},
+		UnreachableTreasures: []string{},
+		AreaDominators:       make(map[int][]string),
+	}
+
+	reachable := a.reachableWithout(-1)
+	report.EndReachable = reachable[a.endArea]
+
+	// 逐个挡住连接点，原本可达而现在不可达的区域就在该连接点身后
+	for _, gate := range g.Gates {
+		blocked := a.reachableWithout(gate.ID)
+		shadow := []int{}
+		for _, area := range g.Areas {
+			if reachable[area.ID] && !blocked[area.ID] && len(area.Positions) > 0 {
+				shadow = append(shadow, area.ID)
+				report.AreaDominators[area.ID] = append(report.AreaDominators[area.ID], gateTitle(gate))
+			}
+		}
+		if len(shadow) == 0 {
+			continue
+		}
+		report.ArticulationGates = append(report.ArticulationGates, &GateShadow{Gate: gateTitle(gate), Areas: shadow})
+		if report.EndReachable && !blocked[a.endArea] {
+			report.MandatoryGates = append(report.MandatoryGates, gateTitle(gate))
+		}
+	}
+
+	for _, area := range g.Areas {
+		if reachable[area.ID] {
+			continue
+		}
+		for _, treasure := range area.Treasures {
+			report.UnreachableTreasures = append(report.UnreachableTreasures,
+				fmt.Sprintf("宝物%d(区域%d)", treasure.OriginalID, area.ID))
+		}
+	}
+	for _, gate := range g.Gates {
+		if gate.Item != nil && !a.touches(gate, reachable) {
+			report.UnreachableTreasures = append(report.UnreachableTreasures, gateTitle(gate))
+		}
+	}
+
+	if report.EndReachable {
+		report.MinYellowKeys, report.MinBlueKeys = a.minKeysToEnd()
+	}
+	return report
+}
+
+// 挡住指定连接点（-1表示不挡）后从起点出发能到达的区域
+// 楼传只能飞到到过的楼梯处，不会增加能到达的区域，这里不考虑
+func (a *graphAnalyzer) reachableWithout(blockedGate int) map[int]bool {
+	accessible := map[int]bool{a.startArea: true}
+	queue := []int{a.startArea}
+	for len(queue) > 0 {
+		currentArea := queue[0]
+		queue = queue[1:]
+
+		next := append([]int{}, a.links[currentArea]...)
+		for _, gate := range a.graph.GatesOf(currentArea) {
+			if gate.ID != blockedGate {
+				for _, area := range a.graph.AreasOf(gate.ID) {
+					next = append(next, area.ID)
+				}
+			}
+		}
+		for _, areaID := range next {
+			if !accessible[areaID] {
+				accessible[areaID] = true
+				queue = append(queue, areaID)
+			}
+		}
+	}
+	return accessible
+}
+
+// 连接点是否与给定区域中的任意一个相邻
+func (a *graphAnalyzer) touches(gate *Gate, areas map[int]bool) bool {
+	for _, area := range a.graph.AreasOf(gate.ID) {
+		if areas[area.ID] {
+			return true
+		}
+	}
+	return false
+}
+
+// 按开门数比较的路线代价
+type keyCost struct {
+	yellow, blue int
+}
+
+// 开门总数少的更优，总数相同时少用蓝钥匙（蓝钥匙更稀有）
+func (c keyCost) less(other keyCost) bool {
+	if c.yellow+c.blue != other.yellow+other.blue {
+		return c.yellow+c.blue < other.yellow+other.blue
+	}
+	return c.blue < other.blue
+}
+
+// 求最少钥匙时堆中的一项：到达区域和到达时开过的门
+type keyRoute struct {
+	area int
+	cost keyCost
+}
+
+// 按开门数排序的最小堆
+type keyRouteHeap []keyRoute
+
+func (h keyRouteHeap) Len() int           { return len(h) }
+func (h keyRouteHeap) Less(i, j int) bool { return h[i].cost.less(h[j].cost) }
+func (h keyRouteHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *keyRouteHeap) Push(x interface{}) { *h = append(*h, x.(keyRoute)) }
+
+func (h *keyRouteHeap) Pop() interface{} {
+	old := *h
+	route := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return route
+}
+
+// 到达终点最少需要开的门：按开门总数求最短路，返回这条路线上的黄门数和蓝门数
+// 楼传不会减少需要开的门（已到达的楼层总能沿原路返回），这里不考虑
+func (a *graphAnalyzer) minKeysToEnd() (int, int) {
+	best := map[int]keyCost{a.startArea: {}}
+	done := make(map[int]bool)
+	routes := &keyRouteHeap{{area: a.startArea}}
+	for routes.Len() > 0 {
+		route := heap.Pop(routes).(keyRoute)
+		if done[route.area] {
+			continue
+		}
+		done[route.area] = true
+		if route.area == a.endArea {
+			return route.cost.yellow, route.cost.blue
+		}
+
+		relax := func(to int, cost keyCost) {
+			if old, exists := best[to]; exists && !cost.less(old) {
+				return
+			}
+			best[to] = cost
+			heap.Push(routes, keyRoute{area: to, cost: cost})
+		}
+		for _, to := range a.links[route.area] {
+			relax(to, route.cost)
+		}
+		for _, gate := range a.graph.GatesOf(route.area) {
+			cost := route.cost
+			switch gate.MonsterID {
+			case YellowDoorID:
+				cost.yellow++
+			case BlueDoorID:
+				cost.blue++
+			}
+			for _, to := range a.graph.AreasOf(gate.ID) {
+				relax(to.ID, cost)
+			}
+		}
+	}
+	return 0, 0
+}
+
+// 报告中连接点的名称，例如"黄门(2,5)"
+func gateTitle(gate *Gate) string {
+	return fmt.Sprintf("%s(%s)", gateLabel(gate), gate.Name())
+}
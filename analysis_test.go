@@ -0,0 +1,146 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 手工搭建的小地图上的关卡分析：必经和可绕开的连接点、割点、拿不到的宝物和最少钥匙
+func TestAnalyze(t *testing.T) {
+	cases := []struct {
+		name         string
+		m            [][]int
+		start, end   [2]int
+		mandatory    [][2]int // 必经连接点的位置
+		articulation [][2]int // 割点连接点的位置
+		unreachable  []string // 拿不到的宝物名称中应包含的内容
+		yellow, blue int
+	}{
+		{
+			// 左右两个怪物都能从左边到中间，只有(1,4)的怪物必须打
+			name: "必经和可绕开",
+			m: [][]int{
+				{1, 1, 1, 1, 1, 1, 1},
+				{1, 0, 213, 0, 213, 0, 1},
+				{1, 0, 1, 0, 1, 1, 1},
+				{1, 0, 213, 0, 1, 1, 1},
+				{1, 1, 1, 1, 1, 1, 1},
+			},
+			start:        [2]int{1, 1},
+			end:          [2]int{1, 5},
+			mandatory:    [][2]int{{1, 4}},
+			articulation: [][2]int{{1, 4}},
+		},
+		{
+			// (1,3)的怪物挡住了血瓶但不挡终点，(3,3)的血瓶被墙围住
+			name: "割点和拿不到的宝物",
+			m: [][]int{
+				{1, 1, 1, 1, 1, 1},
+				{1, 0, 0, 213, 31, 1},
+				{1, 0, 1, 1, 1, 1},
+				{1, 0, 1, 31, 1, 1},
+				{1, 1, 1, 1, 1, 1},
+			},
+			start:        [2]int{1, 1},
+			end:          [2]int{3, 1},
+			articulation: [][2]int{{1, 3}},
+			unreachable:  []string{"宝物31"},
+		},
+		{
+			// 上面的路线要开两扇黄门，下面的路线只开一扇蓝门
+			name: "开门少的路线",
+			m: [][]int{
+				{1, 1, 1, 1, 1, 1, 1},
+				{1, 0, YellowDoorID, 0, YellowDoorID, 0, 1},
+				{1, 0, 1, 1, 1, 0, 1},
+				{1, 0, 0, BlueDoorID, 0, 0, 1},
+				{1, 1, 1, 1, 1, 1, 1},
+			},
+			start: [2]int{1, 1},
+			end:   [2]int{1, 5},
+			blue:  1,
+		},
+		{
+			// 两条路线都只开一扇门时少用蓝钥匙
+			name: "门数相同少用蓝钥匙",
+			m: [][]int{
+				{1, 1, 1, 1, 1, 1, 1},
+				{1, 0, 0, 0, YellowDoorID, 0, 1},
+				{1, 0, 1, 1, 1, 0, 1},
+				{1, 0, 0, BlueDoorID, 0, 0, 1},
+				{1, 1, 1, 1, 1, 1, 1},
+			},
+			start:  [2]int{1, 1},
+			end:    [2]int{1, 5},
+			yellow: 1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			graph := convertForTest(t, c.m, c.start, c.end)
+			titles := func(positions [][2]int) []string {
+				names := []string{}
+				for _, pos := range positions {
+					gate := graph.GateAt(0, pos)
+					if gate == nil {
+						t.Fatalf("位置%v没有连接点", pos)
+					}
+					names = append(names, gateTitle(gate))
+				}
+				return names
+			}
+
+			report := graph.Analyze()
+			if !report.EndReachable {
+				t.Fatal("终点不可达")
+			}
+			if want := titles(c.mandatory); !reflect.DeepEqual(report.MandatoryGates, want) {
+				t.Errorf("必经连接点 = %v, 期望%v", report.MandatoryGates, want)
+			}
+			articulation := []string{}
+			for _, shadow := range report.ArticulationGates {
+				articulation = append(articulation, shadow.Gate)
+			}
+			if want := titles(c.articulation); !reflect.DeepEqual(articulation, want) {
+				t.Errorf("割点连接点 = %v, 期望%v", articulation, want)
+			}
+			if len(report.UnreachableTreasures) != len(c.unreachable) {
+				t.Fatalf("拿不到的宝物 = %v, 期望%v", report.UnreachableTreasures, c.unreachable)
+			}
+			for i, name := range c.unreachable {
+				if !strings.Contains(report.UnreachableTreasures[i], name) {
+					t.Errorf("拿不到的宝物 = %v, 期望%v", report.UnreachableTreasures, c.unreachable)
+				}
+			}
+			if report.MinYellowKeys != c.yellow || report.MinBlueKeys != c.blue {
+				t.Errorf("最少钥匙: 黄%d 蓝%d, 期望黄%d 蓝%d",
+					report.MinYellowKeys, report.MinBlueKeys, c.yellow, c.blue)
+			}
+
+			mandatoryIDs := []int{}
+			for _, pos := range c.mandatory {
+				mandatoryIDs = append(mandatoryIDs, graph.GateAt(0, pos).ID)
+			}
+			if got := graph.MandatoryGateIDs(graph.StartArea, graph.EndArea); !reflect.DeepEqual(got, mandatoryIDs) {
+				t.Errorf("MandatoryGateIDs = %v, 期望%v", got, mandatoryIDs)
+			}
+		})
+	}
+}
+
+// 终点被墙隔开时不可达，也没有必经连接点
+func TestAnalyzeEndUnreachable(t *testing.T) {
+	graph := convertForTest(t, [][]int{
+		{1, 1, 1, 1, 1},
+		{1, 0, 1, 0, 1},
+		{1, 1, 1, 1, 1},
+	}, [2]int{1, 1}, [2]int{1, 3})
+	if report := graph.Analyze(); report.EndReachable || len(report.MandatoryGates) != 0 {
+		t.Errorf("终点可达=%v 必经连接点=%v, 期望不可达且没有必经连接点", report.EndReachable, report.MandatoryGates)
+	}
+	if ids := graph.MandatoryGateIDs(graph.StartArea, graph.EndArea); ids != nil {
+		t.Errorf("MandatoryGateIDs = %v, 期望nil", ids)
+	}
+}
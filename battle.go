@@ -2,6 +2,7 @@ package main
 
 import (
 	"math"
+	"sort"
 )
 
 // 初始化伤害缓存
@@ -11,92 +12,116 @@ func initDamageCache() {
 		for def := minDEF; def <= maxDEF; def++ {
 			damageCache[atk][def] = make(map[int]int16)
 			for monsterID := range monsterMap {
-				monster := monsterMap[monsterID]
-				playerDamage := atk - monster.DEF
-
-				var damage int16
-				if playerDamage <= 0 {
-					damage = maxDamage
-				} else {
-					monsterDamage := int16(math.Max(0, float64(monster.ATK-def)))
-					rounds := int16(math.Ceil(float64(monster.HP)/float64(playerDamage))) - 1
-					damage = rounds * monsterDamage
-				}
-
-				damageCache[atk][def][monsterID] = damage
+				damageCache[atk][def][monsterID] = calculateDamage(atk, def, monsterMap[monsterID])
 			}
 		}
 	}
 }
 
-// 获取预计算的伤害值
+// 计算与怪物战斗损失的血量（用int计算避免溢出，超过上限时返回maxDamage，视为打不过）
+func calculateDamage(playerATK, playerDEF int8, monster *Monster) int16 {
+	playerDamage := int(playerATK) - int(monster.DEF)
+	if playerDamage <= 0 {
+		return maxDamage
+	}
+
+	monsterDamage := max(0, int(monster.ATK)-int(playerDEF))
+	rounds := (int(monster.HP)+playerDamage-1)/playerDamage - 1
+	return int16(min(rounds*monsterDamage, int(maxDamage)))
+}
+
+// 获取预计算的伤害值（超出缓存范围时直接计算，例如穿戴装备后攻防超过上限）
 func getDamage(playerATK, playerDEF int8, monsterID int) int16 {
+	if playerATK < minATK || playerATK > maxATK || playerDEF < minDEF || playerDEF > maxDEF {
+		return calculateDamage(playerATK, playerDEF, monsterMap[monsterID])
+	}
 	return damageCache[playerATK][playerDEF][monsterID]
 }
 
-// 剪枝检查函数
-func shouldPrune(state *State, requiredATK, requiredDEF int8, allMonsters []*GlobalMonster, accessibleAreas map[int]bool) bool {
-	currentAtkDef := state.ATK + state.DEF
-	atkDefImprovement := currentAtkDef - initialAtk - initialDef
-
-	// 剪枝策略1
-	if state.FightsSinceStart >= 4 {
-		if atkDefImprovement == 0 {
-			return true
+// 以给定的攻防和魔法与Boss战斗损失的最少血量（可以使用MP足够的任一技能）
+// 第二个返回值为false表示无论血量多少都打不过
+func bossDamage(atk, def int8, mp int16, monsterID int) (int16, bool) {
+	damage := getDamage(atk, def, monsterID)
+	for _, skill := range skillList {
+		if mp < skill.MPCost {
+			continue
 		}
-	}
-
-	// 剪枝策略1
-	if state.FightsSinceStart >= 6 {
-		if atkDefImprovement <= 1 {
-			return true
+		skillATK, skillDEF := skill.battleStats(atk, def)
+		if skillDamage := getDamage(skillATK, skillDEF, monsterID); skillDamage < damage {
+			damage = skillDamage
 		}
 	}
+	return damage, damage < maxDamage
+}
 
-	// 剪枝策略2
-	if state.FightsSinceStart >= 7 {
-		if atkDefImprovement <= 2 {
-			return true
+// 以Boss为目标时连续战斗剪枝的攻防要求：不使用技能、用hp点血能打赢Boss的最低攻击，以及该攻击下需要的最低防御
+// 多少攻击都打不赢时返回0, 0，不作要求
+func bossTarget(monsterID int, hp int16) (int8, int8) {
+	for atk := int8(0); atk < math.MaxInt8; atk++ {
+		if _, beatable := bossDamage(atk, math.MaxInt8, 0, monsterID); !beatable {
+			continue
 		}
-	}
-
-	if state.Money > 45 {
-		return true
-	}
-
-	// 剪枝策略3
-	// 注意：只计算非零伤害的怪物
-	if state.ConsecutiveFights >= 5 && (state.ATK < requiredATK-2 || state.DEF < requiredDEF-2) {
-		return true
-	}
-
-	// 剪枝策略1: 如果打了10只怪之后攻防和比初始攻防和只高5点或更少，则停止扩展该路线
-	if state.FightsSinceStart >= 11 {
-		if atkDefImprovement <= 4 {
-			return true
+		for def := int8(0); def < math.MaxInt8; def++ {
+			if getDamage(atk, def, monsterID) < hp {
+				return atk, def
+			}
 		}
 	}
+	return 0, 0
+}
+
+// 走廊怪物链的一种打法
+type chainPlan struct {
+	mpCost int16    // 用掉的MP
+	damage int      // 总伤害（用int累加，避免溢出）
+	parts  []Action // 每一场战斗
+}
 
-	// 剪枝策略1: 如果打了10只怪之后攻防和比初始攻防和只高5点或更少，则停止扩展该路线
-	if state.FightsSinceStart >= 16 {
-		if atkDefImprovement <= 7 {
-			return true
+// 依次与怪物链中的怪物战斗的所有不被支配的打法：每场战斗可以不使用技能，或使用任一MP足够的技能
+// 用掉的MP相同时只保留总伤害最小的打法，结果按用掉的MP从小到大排列、总伤害严格递减，打不过时为空
+func chainPlans(chain []*Gate, atk, def int8, mp int16) []chainPlan {
+	plans := []chainPlan{{}}
+	for _, member := range chain {
+		best := make(map[int16]chainPlan)
+		for _, plan := range plans {
+			for skillIdx := -1; skillIdx < len(skillList); skillIdx++ {
+				battleATK, battleDEF, cost := atk, def, int16(0)
+				if skillIdx >= 0 {
+					battleATK, battleDEF = skillList[skillIdx].battleStats(atk, def)
+					cost = skillList[skillIdx].MPCost
+				}
+				if plan.mpCost+cost > mp {
+					continue
+				}
+				damage := getDamage(battleATK, battleDEF, member.MonsterID)
+				if damage >= maxDamage {
+					continue
+				}
+				total := plan.damage + int(damage)
+				if old, exists := best[plan.mpCost+cost]; exists && old.damage <= total {
+					continue
+				}
+				parts := append(make([]Action, 0, len(plan.parts)+1), plan.parts...)
+				best[plan.mpCost+cost] = chainPlan{
+					mpCost: plan.mpCost + cost,
+					damage: total,
+					parts:  append(parts, Action{Type: actionFight, Damage: damage, Floor: member.Floor, Pos: member.Pos, Skill: int8(skillIdx)}),
+				}
+			}
 		}
-	}
 
-	// 剪枝策略1: 如果打了10只怪之后攻防和比初始攻防和只高5点或更少，则停止扩展该路线
-	if state.FightsSinceStart >= 21 {
-		if atkDefImprovement <= 9 {
-			return true
+		plans = make([]chainPlan, 0, len(best))
+		for _, plan := range best {
+			plans = append(plans, plan)
 		}
+		sort.Slice(plans, func(i, j int) bool { return plans[i].mpCost < plans[j].mpCost })
 	}
 
-	// 剪枝策略1: 如果打了10只怪之后攻防和比初始攻防和只高5点或更少，则停止扩展该路线
-	if state.FightsSinceStart >= 27 {
-		if atkDefImprovement <= 12 {
-			return true
+	result := []chainPlan{}
+	for _, plan := range plans {
+		if len(result) == 0 || plan.damage < result[len(result)-1].damage {
+			result = append(result, plan)
 		}
 	}
-
-	return false
+	return result
 }
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+// 伤害用int计算，超过上限时视为打不过
+func TestCalculateDamageOverflow(t *testing.T) {
+	giant := &Monster{HP: 30000, ATK: 100, DEF: 0}
+	if damage := calculateDamage(1, 0, giant); damage != maxDamage {
+		t.Errorf("伤害 = %d, 期望maxDamage", damage)
+	}
+	// 127-(-128)在int8中会溢出为负数，一击就能打死
+	if damage := calculateDamage(127, -128, &Monster{HP: 10, ATK: 127, DEF: -128}); damage != 0 {
+		t.Errorf("伤害 = %d, 期望0", damage)
+	}
+}
+
+// 技能后的攻防不受伤害缓存范围限制，只在int8范围内取边界值
+func TestSkillBattleStats(t *testing.T) {
+	doubleSlash := &Skill{Name: "二倍斩", ATKMultiplier: 2}
+	shield := &Skill{Name: "护盾", DEFBonus: 5}
+	cases := []struct {
+		skill            *Skill
+		atk, def         int8
+		wantATK, wantDEF int8
+	}{
+		{doubleSlash, 15, 6, 30, 6},
+		{doubleSlash, 100, 6, 127, 6},
+		{shield, 15, 18, 15, 23},
+		{shield, 15, 125, 15, 127},
+	}
+	for _, c := range cases {
+		if atk, def := c.skill.battleStats(c.atk, c.def); atk != c.wantATK || def != c.wantDEF {
+			t.Errorf("%s(%d, %d) = (%d, %d), 期望(%d, %d)", c.skill.Name, c.atk, c.def, atk, def, c.wantATK, c.wantDEF)
+		}
+	}
+
+	initDamageCache()
+	const monster = 214 // 防御12
+	if damage := getDamage(30, 6, monster); damage >= maxDamage {
+		t.Fatalf("攻击30对防御12的怪物伤害 = %d, 期望能打过", damage)
+	}
+	damage, beatable := bossDamage(15, 6, 10, monster)
+	if want := getDamage(30, 6, monster); !beatable || damage != want {
+		t.Errorf("攻击15使用二倍斩的伤害 = %d, 期望按攻击30计算为%d", damage, want)
+	}
+}
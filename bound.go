@@ -0,0 +1,89 @@
+package main
+
+// 分支定界用的乐观估计：一个状态最终最多还能剩下多少血
+// 假设剩下的血瓶、攻防宝物、装备和NPC奖励中有益的部分全部拿到且不付出任何代价，
+// 必经的怪物按能达到的最高攻防（并叠加所有技能）计算伤害，因此不会低估
+type hpBound struct {
+	treasures []*GlobalTreasure
+	gates     []*Gate
+	mandatory []int // 满足终点条件必须击败的连接点ID
+	skillATK  int   // 技能能带来的最大攻击倍数
+	skillDEF  int   // 技能能带来的最大防御加成
+	maxBuys   int   // 商店购买攻防的次数上限
+}
+
+func newHPBound(graph *Graph, allTreasures []*GlobalTreasure, mandatory []int) *hpBound {
+	b := &hpBound{
+		treasures: allTreasures,
+		gates:     graph.Gates,
+		mandatory: mandatory,
+		skillATK:  1,
+		maxBuys:   3,
+	}
+	for _, skill := range skillList {
+		if int(skill.ATKMultiplier) > b.skillATK {
+			b.skillATK = int(skill.ATKMultiplier)
+		}
+		if int(skill.DEFBonus) > b.skillDEF {
+			b.skillDEF = int(skill.DEFBonus)
+		}
+	}
+	return b
+}
+
+// 状态最终血量的上限
+func (b *hpBound) upperBound(state *State) int {
+	hp := int(state.HP)
+	atk := int(state.TotalATK()) + b.maxBuys - int(state.ATKBuys)
+	def := int(state.TotalDEF()) + b.maxBuys - int(state.DEFBuys)
+	if state.Ailments&ailmentWeak != 0 {
+		atk += int(weakenATK)
+		def += int(weakenDEF)
+	}
+
+	// 降低属性的物品和交易可以不拿，只累加正的收益
+	gain := func(treasureType int, value int8, equip *Equipment) {
+		switch treasureType {
+		case treasureHP:
+			hp += max(0, int(value))
+		case treasureATK:
+			atk += max(0, int(value))
+		case treasureDEF:
+			def += max(0, int(value))
+		case treasureEquipment:
+			atk += max(0, int(equip.ATK))
+			def += max(0, int(equip.DEF))
+		}
+	}
+	for idx, treasure := range b.treasures {
+		if !state.CollectedTreasures.IsSet(idx) {
+			gain(treasure.Type, treasure.Value, treasure.Equip)
+		}
+	}
+	for _, gate := range b.gates {
+		if state.DefeatedMonsters.IsSet(gate.ID) {
+			continue
+		}
+		if gate.Item != nil {
+			gain(gate.Item.Type, gate.Item.Value, gate.Item.Equip)
+		}
+		if gate.NPC != nil {
+			hp += max(0, int(gate.NPC.Gain.HP))
+			atk += max(0, int(gate.NPC.Gain.ATK))
+			def += max(0, int(gate.NPC.Gain.DEF))
+		}
+	}
+
+	battleATK, battleDEF := clampInt8(atk*b.skillATK), clampInt8(def+b.skillDEF)
+	for _, gateID := range b.mandatory {
+		if state.DefeatedMonsters.IsSet(gateID) {
+			continue
+		}
+		for _, member := range chainOf(b.gates[gateID]) {
+			if member.isMonster() && member.Monster != nil {
+				hp -= int(calculateDamage(battleATK, battleDEF, member.Monster))
+			}
+		}
+	}
+	return hp
+}
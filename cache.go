@@ -5,11 +5,11 @@ import "fmt"
 // 扩展的可达性缓存（需要适配ExtendedBitSet）
 type AccessibilityCacheExt struct {
 	cache    map[string]map[int]bool
-	monsters []*GlobalMonster
+	monsters []*Gate
 	maxSize  int
 }
 
-func NewAccessibilityCacheExt(monsters []*GlobalMonster, cacheSize int) *AccessibilityCacheExt {
+func NewAccessibilityCacheExt(monsters []*Gate, cacheSize int) *AccessibilityCacheExt {
 	return &AccessibilityCacheExt{
 		cache:    make(map[string]map[int]bool),
 		monsters: monsters,
@@ -33,7 +33,7 @@ func (cache *AccessibilityCacheExt) GetAccessibleAreas(defeated *ExtendedBitSet,
 	for i := 0; i < len(cache.monsters); i++ {
 		if defeated.IsSet(i) {
 			monster := cache.monsters[i]
-			for _, areaID := range monster.ConnectedAreas {
+			for _, areaID := range monster.Areas {
 				accessible[areaID] = true
 			}
 		}
@@ -65,7 +65,7 @@ func (cache *AccessibilityCacheExt) GetAccessibleAreasIncremental(oldDefeated *E
 	newMonster := cache.monsters[newMonsterIdx]
 
 	// 将新怪物连接的区域添加到可达区域中
-	for _, areaID := range newMonster.ConnectedAreas {
+	for _, areaID := range newMonster.Areas {
 		newAccessible[areaID] = true
 	}
 
@@ -89,7 +89,7 @@ func (cache *AccessibilityCacheExt) GetAccessibleAreasIncremental(oldDefeated *E
 
 			// 检查这个怪物是否在任何可达区域中
 			monsterReachable := false
-			for _, areaID := range monster.ConnectedAreas {
+			for _, areaID := range monster.Areas {
 				if newAccessible[areaID] {
 					monsterReachable = true
 					break
@@ -98,7 +98,7 @@ func (cache *AccessibilityCacheExt) GetAccessibleAreasIncremental(oldDefeated *E
 
 			// 如果怪物可达，确保其连接的所有区域都被标记为可达
 			if monsterReachable {
-				for _, areaID := range monster.ConnectedAreas {
+				for _, areaID := range monster.Areas {
 					if !newAccessible[areaID] {
 						newAccessible[areaID] = true
 						changed = true // 有新区域被开启，需要再次检查
@@ -7,20 +7,52 @@ const (
 	minDEF    = int8(5)
 	maxDEF    = int8(20)
 	maxMDEF   = int8(20)
-	maxDamage = int16(9999)
+	maxDamage = int16(1<<15 - 1) // 打不过的怪物的伤害，不小于任何血量
 )
 
 // 物品相关常量
 const (
-	treasureHP        = 0
-	treasureATK       = 1
-	treasureDEF       = 2
-	treasureYellowKey = 3
-	treasureBlueKey   = 4
-	treasureMDEF      = 5
+	treasureHP         = 0
+	treasureATK        = 1
+	treasureDEF        = 2
+	treasureYellowKey  = 3
+	treasureBlueKey    = 4
+	treasureMDEF       = 5
+	treasureEquipment  = 6  // 装备：替换同部位的加成而不是累加
+	treasureCurePoison = 7  // 解毒药水
+	treasureCureWeak   = 8  // 解衰药水
+	treasureCureCurse  = 9  // 解咒药水
+	treasureMP         = 10 // 魔法值
 
-	maxYellowKey = int8(1<<3 - 1)
-	maxBlueKey   = int8(1<<2 - 1)
+	maxYellowKey = int16(1<<3 - 1)
+	maxBlueKey   = int16(1<<2 - 1)
+)
+
+// 状态异常（同时用作怪物特殊能力：击败后使勇士陷入对应状态）
+const (
+	ailmentPoison = uint8(1 << iota) // 中毒：每走一步损失生命
+	ailmentWeak                      // 衰弱：攻防下降
+	ailmentCurse                     // 诅咒：战斗不再获得金币
+)
+
+// 装备部位
+const (
+	slotWeapon     = 0 // 剑
+	slotShield     = 1 // 盾
+	equipSlotCount = 2
+)
+
+// 路径动作类型
+const (
+	actionNone     = iota // 初始状态，没有动作
+	actionFight           // 战斗（包括开门）
+	actionBuyATK          // 购买攻击力
+	actionBuyDEF          // 购买防御力
+	actionNPC             // 与NPC交易
+	actionPickItem        // 拾取可选物品
+	actionCure            // 在商店解除状态异常
+	actionMove            // 经单向通道或传送门进入回不来的区域
+	actionTrigger         // 踩上机关格
 )
 
 // 位操作常量
@@ -32,10 +64,28 @@ const (
 
 // 地图元素常量
 const (
-	YellowDoorID = 81 // 黄门
-	BlueDoorID   = 82 // 蓝门
+	YellowDoorID    = 81 // 黄门
+	BlueDoorID      = 82 // 蓝门
+	UpStairsID      = 87 // 上楼梯
+	DownStairsID    = 88 // 下楼梯
+	MechanismDoorID = 85 // 机关门（开启条件由触发器配置）
+	PortalID        = 89 // 传送门（目标位置由转换器配置）
+
+	// 单向通道：只能沿箭头方向通过
+	ArrowUpID    = 161
+	ArrowDownID  = 162
+	ArrowLeftID  = 163
+	ArrowRightID = 164
 )
 
+// 单向通道的通行方向
+var arrowDirections = map[int][2]int{
+	ArrowUpID:    {-1, 0},
+	ArrowDownID:  {1, 0},
+	ArrowLeftID:  {0, -1},
+	ArrowRightID: {0, 1},
+}
+
 // 算法常量
 const (
 	maxIterations = 1 << 50
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 图的JSON格式，供其他工具读取，也可以重新加载以跳过地图转换（字段名与Go结构体一致）
+type graphJSON struct {
+	Start         int
+	End           int
+	Areas         []areaJSON
+	Gates         []gateJSON
+	BreakPoints   []*BreakPoint
+	Links         []*AreaLink
+	CenterPos     [2]int
+	CenterFly     []centerFlyJSON
+	AreaMap       [][]int
+	FloorAreaMaps [][][]int   `json:",omitempty"`
+	StairAreas    [][]int     `json:",omitempty"`
+	Teleporter    *Teleporter `json:",omitempty"`
+	Triggers      []*Trigger  `json:",omitempty"`
+}
+
+type areaJSON struct {
+	ID        int
+	Floor     int
+	Positions [][2]int
+	Treasures []*TreasureItem
+	Beside    *[2]int `json:",omitempty"`
+	Exit      bool    `json:",omitempty"`
+}
+
+type gateJSON struct {
+	ID        int
+	MonsterID int
+	Floor     int
+	Pos       [2]int
+	Areas     []int
+	Dynamic   *DynamicCell `json:",omitempty"`
+	Chain     []gateJSON   `json:",omitempty"`
+}
+
+type centerFlyJSON struct {
+	From    int
+	Targets []*CenterFlyTarget
+}
+
+// 导出连接点（宏连接点连同怪物链一起导出）
+func gateToJSON(gate *Gate) gateJSON {
+	result := gateJSON{
+		ID:        gate.ID,
+		MonsterID: gate.MonsterID,
+		Floor:     gate.Floor,
+		Pos:       gate.Pos,
+		Areas:     gate.Areas,
+		Dynamic:   gate.Dynamic,
+	}
+	for _, member := range gate.Chain {
+		result.Chain = append(result.Chain, gateToJSON(member))
+	}
+	return result
+}
+
+// 把图导出为JSON
+func (g *Graph) ToJSON() ([]byte, error) {
+	data := graphJSON{
+		Start:         g.StartArea,
+		End:           g.EndArea,
+		Areas:         make([]areaJSON, 0, len(g.Areas)),
+		Gates:         make([]gateJSON, 0, len(g.Gates)),
+		BreakPoints:   g.BreakPoints,
+		Links:         g.Links,
+		CenterPos:     g.centerPos,
+		CenterFly:     []centerFlyJSON{},
+		AreaMap:       g.AreaMap,
+		FloorAreaMaps: g.FloorAreaMaps,
+		StairAreas:    g.StairAreas,
+		Teleporter:    g.Teleporter,
+		Triggers:      g.Triggers,
+	}
+	for _, area := range g.Areas {
+		data.Areas = append(data.Areas, areaJSON{
+			ID:        area.ID,
+			Floor:     area.Floor,
+			Positions: area.Positions,
+			Treasures: area.Treasures,
+			Beside:    area.Beside,
+			Exit:      area.Exit,
+		})
+	}
+	for _, gate := range g.Gates {
+		data.Gates = append(data.Gates, gateToJSON(gate))
+	}
+	for _, area := range g.Areas {
+		if result := g.centerFlyCache[area.ID]; result != nil && len(result.Targets) > 0 {
+			data.CenterFly = append(data.CenterFly, centerFlyJSON{From: area.ID, Targets: result.Targets})
+		}
+	}
+	return json.MarshalIndent(data, "", "  ")
+}
+
+// 从JSON加载图，怪物、NPC和可选物品按元素ID从给定的表中恢复；区域ID超出范围或元素ID未知时返回错误
+// 加载的图没有原始地图，WithCellChanged会返回错误
+func LoadGraphJSON(raw []byte, treasureMap map[int]*Treasure, monsterMap map[int]*Monster, npcMap map[int]*NPC) (*Graph, error) {
+	var data graphJSON
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+
+	graph := &Graph{
+		Areas:          make([]*Area, len(data.Areas)),
+		StartArea:      data.Start,
+		EndArea:        data.End,
+		AreaMap:        data.AreaMap,
+		Gates:          make([]*Gate, 0, len(data.Gates)),
+		BreakPoints:    data.BreakPoints,
+		Links:          data.Links,
+		FloorAreaMaps:  data.FloorAreaMaps,
+		StairAreas:     data.StairAreas,
+		Teleporter:     data.Teleporter,
+		Triggers:       data.Triggers,
+		centerPos:      data.CenterPos,
+		centerFlyCache: make(map[int]*CenterFlyResult),
+	}
+	if graph.BreakPoints == nil {
+		graph.BreakPoints = []*BreakPoint{}
+	}
+	if graph.Links == nil {
+		graph.Links = []*AreaLink{}
+	}
+
+	// 区域ID必须在范围内（起点和终点可以为-1，表示不在图中）
+	validArea := func(areaID int) bool {
+		return areaID >= 0 && areaID < len(data.Areas)
+	}
+	if (data.Start != -1 && !validArea(data.Start)) || (data.End != -1 && !validArea(data.End)) {
+		return nil, fmt.Errorf("起点区域 %d 或终点区域 %d 超出范围", data.Start, data.End)
+	}
+	for _, link := range graph.Links {
+		if link == nil || !validArea(link.From) || !validArea(link.To) {
+			return nil, fmt.Errorf("区域连接 %+v 的区域ID超出范围", link)
+		}
+	}
+	for floor, stairAreas := range graph.StairAreas {
+		for _, areaID := range stairAreas {
+			if !validArea(areaID) {
+				return nil, fmt.Errorf("第%d层的楼梯区域 %d 超出范围", floor, areaID)
+			}
+		}
+	}
+
+	for _, area := range data.Areas {
+		if !validArea(area.ID) || graph.Areas[area.ID] != nil {
+			return nil, fmt.Errorf("区域ID %d 超出范围或重复", area.ID)
+		}
+		if area.Treasures == nil {
+			area.Treasures = []*TreasureItem{}
+		}
+		graph.Areas[area.ID] = &Area{
+			ID:        area.ID,
+			Floor:     area.Floor,
+			Treasures: area.Treasures,
+			Gates:     []int{},
+			Positions: area.Positions,
+			Beside:    area.Beside,
+			Exit:      area.Exit,
+		}
+	}
+
+	var gateFromJSON func(data gateJSON) (*Gate, error)
+	gateFromJSON = func(data gateJSON) (*Gate, error) {
+		for _, areaID := range data.Areas {
+			if !validArea(areaID) {
+				return nil, fmt.Errorf("连接点 %v 的区域ID %d 超出范围", data.Pos, areaID)
+			}
+		}
+		_, isMonster := monsterMap[data.MonsterID]
+		_, isNPC := npcMap[data.MonsterID]
+		treasure, isTreasure := treasureMap[data.MonsterID]
+		if data.Dynamic == nil && len(data.Chain) == 0 && !isMonster && !isNPC && !(isTreasure && treasure.Optional) {
+			return nil, fmt.Errorf("连接点 %v 的怪物ID %d 未知", data.Pos, data.MonsterID)
+		}
+		gate := &Gate{
+			ID:        data.ID,
+			MonsterID: data.MonsterID,
+			Monster:   monsterMap[data.MonsterID],
+			NPC:       npcMap[data.MonsterID],
+			Dynamic:   data.Dynamic,
+			Floor:     data.Floor,
+			Pos:       data.Pos,
+			Areas:     data.Areas,
+		}
+		if isTreasure && treasure.Optional {
+			gate.Item = treasure
+		}
+		for _, member := range data.Chain {
+			memberGate, err := gateFromJSON(member)
+			if err != nil {
+				return nil, err
+			}
+			gate.Chain = append(gate.Chain, memberGate)
+		}
+		return gate, nil
+	}
+	for _, gateData := range data.Gates {
+		gate, err := gateFromJSON(gateData)
+		if err != nil {
+			return nil, err
+		}
+		graph.Gates = append(graph.Gates, gate)
+	}
+	graph.indexGates()
+	err := checkTriggers(graph.Triggers, func(trigger *Trigger, pos [2]int) bool {
+		return graph.GateAt(trigger.Floor, pos) != nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = checkNPCRequirements(graph.Gates, func(floor int, pos [2]int) bool {
+		return graph.GateAt(floor, pos) != nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, area := range graph.Areas {
+		graph.centerFlyCache[area.ID] = &CenterFlyResult{
+			FromArea:    area.ID,
+			Targets:     []*CenterFlyTarget{},
+			TargetAreas: make(map[int]*CenterFlyTarget),
+		}
+	}
+	for _, flight := range data.CenterFly {
+		result := graph.centerFlyCache[flight.From]
+		if result == nil {
+			return nil, fmt.Errorf("中心飞起点区域 %d 不存在", flight.From)
+		}
+		for _, target := range flight.Targets {
+			result.Targets = append(result.Targets, target)
+			result.TargetAreas[target.TargetArea] = target
+		}
+	}
+
+	return graph, nil
+}
+
+// 把图导出为Graphviz DOT格式：区域为方框，连接点为椭圆，单向连接为箭头，破墙点为虚线，中心飞为点线
+func (g *Graph) ToDOT() string {
+	var b strings.Builder
+	b.WriteString("digraph G {\n")
+	b.WriteString("  node [fontname=\"sans-serif\"];\n")
+
+	for _, area := range g.Areas {
+		if len(area.Positions) == 0 && area.Beside == nil {
+			continue // 增量修改后留下的空区域
+		}
+		label := fmt.Sprintf("区域%d\\n%d格", area.ID, len(area.Positions))
+		if area.Beside != nil {
+			label = fmt.Sprintf("区域%d\\n落脚点%v", area.ID, *area.Beside)
+		}
+		if len(area.Treasures) > 0 {
+			label += fmt.Sprintf(" 宝物%d", len(area.Treasures))
+		}
+		attrs := ""
+		switch area.ID {
+		case g.StartArea:
+			label += "\\n起点"
+			attrs = ", style=filled, fillcolor=palegreen"
+		case g.EndArea:
+			label += "\\n终点"
+			attrs = ", style=filled, fillcolor=lightpink"
+		}
+		fmt.Fprintf(&b, "  a%d [shape=box, label=\"%s\"%s];\n", area.ID, label, attrs)
+	}
+
+	for _, gate := range g.Gates {
+		fmt.Fprintf(&b, "  g%d [shape=%s, label=\"%s\\n%s\"];\n", gate.ID, gateShape(gate), gateLabel(gate), gate.Name())
+		for _, area := range g.AreasOf(gate.ID) {
+			fmt.Fprintf(&b, "  a%d -> g%d [dir=none];\n", area.ID, gate.ID)
+		}
+	}
+
+	for _, link := range g.Links {
+		fmt.Fprintf(&b, "  a%d -> a%d [color=blue];\n", link.From, link.To)
+	}
+
+	for _, bp := range g.BreakPoints {
+		for i := 1; i < len(bp.AreaIDs); i++ {
+			fmt.Fprintf(&b, "  a%d -> a%d [dir=none, style=dashed, label=\"破墙 %d,%d\"];\n",
+				bp.AreaIDs[0], bp.AreaIDs[i], bp.Pos[0], bp.Pos[1])
+		}
+	}
+
+	fromAreas := make([]int, 0, len(g.centerFlyCache))
+	for areaID := range g.centerFlyCache {
+		fromAreas = append(fromAreas, areaID)
+	}
+	sort.Ints(fromAreas)
+	for _, from := range fromAreas {
+		for _, target := range g.centerFlyCache[from].Targets {
+			if target.TargetArea != from {
+				fmt.Fprintf(&b, "  a%d -> a%d [style=dotted, color=gray];\n", from, target.TargetArea)
+			}
+		}
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// 连接点在DOT中的形状
+func gateShape(gate *Gate) string {
+	switch {
+	case gate.NPC != nil:
+		return "house"
+	case gate.Item != nil:
+		return "note"
+	case gate.Dynamic != nil:
+		return "octagon"
+	case gate.MonsterID == YellowDoorID || gate.MonsterID == BlueDoorID:
+		return "diamond"
+	}
+	return "ellipse"
+}
+
+// 连接点在DOT中的标签
+func gateLabel(gate *Gate) string {
+	switch {
+	case len(gate.Chain) > 0:
+		return fmt.Sprintf("怪物链x%d", len(gate.Chain))
+	case gate.NPC != nil:
+		return fmt.Sprintf("NPC%d", gate.MonsterID)
+	case gate.Item != nil:
+		return fmt.Sprintf("物品%d", gate.MonsterID)
+	case gate.Dynamic != nil:
+		return fmt.Sprintf("机关%d", gate.Dynamic.Trigger)
+	case gate.MonsterID == YellowDoorID:
+		return "黄门"
+	case gate.MonsterID == BlueDoorID:
+		return "蓝门"
+	}
+	return fmt.Sprintf("怪物%d", gate.MonsterID)
+}
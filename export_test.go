@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 导出后重新加载的图再次导出时内容不变
+func TestGraphJSONRoundTrip(t *testing.T) {
+	graph := convertForTest(t, sampleMap, [2]int{5, 3}, [2]int{0, 3})
+	data, err := graph.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadGraphJSON(data, treasureMap, monsterMap, npcMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reexported, err := loaded.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reexported) != string(data) {
+		t.Error("重新加载后导出的JSON与原来不同")
+	}
+}
+
+// 格式错误、区域ID超出范围或元素ID未知的JSON返回错误而不是留下空的怪物
+func TestLoadGraphJSONInvalid(t *testing.T) {
+	graph := convertForTest(t, sampleMap, [2]int{5, 3}, [2]int{0, 3})
+	raw, err := graph.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name   string
+		modify func(data *graphJSON)
+	}{
+		{"起点超出范围", func(data *graphJSON) { data.Start = len(data.Areas) }},
+		{"终点为负数", func(data *graphJSON) { data.End = -2 }},
+		{"连接的终点超出范围", func(data *graphJSON) {
+			data.Links = append(data.Links, &AreaLink{From: 0, To: len(data.Areas)})
+		}},
+		{"连接点的区域超出范围", func(data *graphJSON) { data.Gates[0].Areas = []int{-1} }},
+		{"未知的怪物ID", func(data *graphJSON) { data.Gates[0].MonsterID = 9999 }},
+	}
+	for _, c := range cases {
+		var data graphJSON
+		if err := json.Unmarshal(raw, &data); err != nil {
+			t.Fatal(err)
+		}
+		c.modify(&data)
+		modified, err := json.Marshal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadGraphJSON(modified, treasureMap, monsterMap, npcMap); err == nil {
+			t.Errorf("%s: 期望返回错误", c.name)
+		}
+	}
+
+	if _, err := LoadGraphJSON([]byte(`{"Areas": [`), treasureMap, monsterMap, npcMap); err == nil {
+		t.Error("格式错误的JSON: 期望返回错误")
+	}
+}
+
+// 从导出的文件加载的图与转换得到的图搜索结果相同
+func TestLoadGraphFile(t *testing.T) {
+	initDamageCache()
+	c := sampleCases[0]
+	graph := convertForTest(t, c.m, c.start, c.end)
+	data, err := graph.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "graph.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadGraphFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := &SearchOptions{Prune: &PruneConfig{Exact: true}}
+	if converted, reloaded := searchSample(t, graph, c, options), searchSample(t, loaded, c, options); converted.HP != reloaded.HP || converted.Money != reloaded.Money {
+		t.Errorf("转换的图 HP=%d 金币=%d, 加载的图 HP=%d 金币=%d", converted.HP, converted.Money, reloaded.HP, reloaded.Money)
+	}
+	if _, err := loadGraphFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("文件不存在时应该返回错误")
+	}
+}
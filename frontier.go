@@ -0,0 +1,236 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// 搜索的DP表和优先队列，可以被多个协程同时使用
+// DP表按key分片加锁；每个协程有自己的优先队列，新状态放入产生它的协程的队列，
+// 队列空了的协程从其他协程的队列中窃取一批优先级最高的状态
+type searchFrontier struct {
+	shards []dpShard
+	queues []workerQueue
+
+	pending    int64       // 还没扩展完的状态数（队列中的加上正在扩展的），为0时搜索结束
+	iterations int64       // 已出队的状态数
+	stored     int64       // 加入过DP表的状态数
+	stopped    atomic.Bool // 被取消或预算用尽
+
+	// 没有状态可取的协程在wake上等待，有新状态、搜索结束或被停止时唤醒
+	idle    sync.Mutex
+	wake    *sync.Cond
+	waiting int32 // 正在等待的协程数，为0时不需要唤醒
+
+	maxIterations int64 // 出队状态数上限
+	maxStates     int64 // 加入DP表的状态数上限，0表示不限制
+}
+
+// DP表的一个分片：每个key保存互不支配的状态（帕累托前沿）
+type dpShard struct {
+	mu     sync.Mutex
+	states map[StateKey][]*State
+}
+
+// 一个协程的优先队列
+type workerQueue struct {
+	mu    sync.Mutex
+	queue PriorityQueue
+}
+
+// 一次最多窃取的状态数
+const stealBatch = 32
+
+// 还没有分支定界门槛
+const noThreshold = math.MinInt64
+
+func newSearchFrontier(shardCount, workers int) *searchFrontier {
+	f := &searchFrontier{
+		shards:        make([]dpShard, shardCount),
+		queues:        make([]workerQueue, workers),
+		maxIterations: maxIterations,
+	}
+	for i := range f.shards {
+		f.shards[i].states = make(map[StateKey][]*State)
+	}
+	f.wake = sync.NewCond(&f.idle)
+	return f
+}
+
+// key的哈希值，用于选择分片
+func (k StateKey) hash() uint64 {
+	h := uint64(k.Packed)*0x9E3779B97F4A7C15 ^ uint64(k.Area)
+	for i := 0; i < len(k.Wide); i++ {
+		h = (h ^ uint64(k.Wide[i])) * 1099511628211
+	}
+	return h
+}
+
+func (f *searchFrontier) shard(key StateKey) *dpShard {
+	return &f.shards[key.hash()%uint64(len(f.shards))]
+}
+
+// 新状态不被前沿中的任何状态支配时加入前沿和第worker个协程的优先队列，并淘汰被它支配的状态
+func (f *searchFrontier) relax(worker int, key StateKey, state *State) {
+	shard := f.shard(key)
+	shard.mu.Lock()
+	frontier := shard.states[key]
+	for _, existingState := range frontier {
+		if existingState.dominates(state) {
+			shard.mu.Unlock()
+			return
+		}
+	}
+	kept := frontier[:0]
+	for _, existingState := range frontier {
+		if !state.dominates(existingState) {
+			kept = append(kept, existingState)
+		}
+	}
+	shard.states[key] = append(kept, state)
+	shard.mu.Unlock()
+
+	// 优先级只决定扩展顺序，不影响结果
+	// 先计数再入队，其他协程看到pending为0时队列中一定没有状态
+	priority := calculatePriority(state.HP, state.MP, state.Money, state.FightsSinceStart)
+	atomic.AddInt64(&f.pending, 1)
+	q := &f.queues[worker]
+	q.mu.Lock()
+	heap.Push(&q.queue, &StateItem{Key: key, State: state, Priority: priority})
+	q.mu.Unlock()
+	f.notify(false)
+	if stored := atomic.AddInt64(&f.stored, 1); f.maxStates > 0 && stored >= f.maxStates {
+		f.stop()
+	}
+}
+
+// 状态是否仍在前沿中（出队前可能已被更优的状态淘汰）
+func (f *searchFrontier) isCurrent(key StateKey, state *State) bool {
+	shard := f.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	for _, existingState := range shard.states[key] {
+		if existingState == state {
+			return true
+		}
+	}
+	return false
+}
+
+// 第worker个协程取出下一个状态：先取自己队列中优先级最高的，队列为空时窃取其他协程的状态，
+// 都没有时等待其他协程产生新状态
+// 所有状态都已扩展完、搜索被停止或出队次数达到上限时返回false
+func (f *searchFrontier) pop(worker int) (*StateItem, bool) {
+	for !f.stopped.Load() {
+		// 先占用一次出队次数，没有取到状态时归还
+		if atomic.AddInt64(&f.iterations, 1) > f.maxIterations {
+			atomic.AddInt64(&f.iterations, -1)
+			f.stop()
+			break
+		}
+		if item := f.take(worker); item != nil {
+			return item, true
+		}
+		atomic.AddInt64(&f.iterations, -1)
+		if atomic.LoadInt64(&f.pending) == 0 {
+			return nil, false
+		}
+		f.wait()
+	}
+	return nil, false
+}
+
+// 等待其他协程产生新状态，所有状态都已扩展完或搜索被停止时也会返回
+// 先登记等待再检查队列：relax先入队再检查是否有协程在等待，两边至少有一边能看到对方
+func (f *searchFrontier) wait() {
+	f.idle.Lock()
+	atomic.AddInt32(&f.waiting, 1)
+	for !f.stopped.Load() && atomic.LoadInt64(&f.pending) > 0 && !f.hasQueued() {
+		f.wake.Wait()
+	}
+	atomic.AddInt32(&f.waiting, -1)
+	f.idle.Unlock()
+}
+
+// 唤醒等待的协程：有一个新状态时唤醒一个，搜索结束或被停止时全部唤醒
+func (f *searchFrontier) notify(all bool) {
+	if atomic.LoadInt32(&f.waiting) == 0 {
+		return
+	}
+	f.idle.Lock()
+	if all {
+		f.wake.Broadcast()
+	} else {
+		f.wake.Signal()
+	}
+	f.idle.Unlock()
+}
+
+// 是否有协程的队列中还有状态
+func (f *searchFrontier) hasQueued() bool {
+	for i := range f.queues {
+		q := &f.queues[i]
+		q.mu.Lock()
+		remaining := q.queue.Len()
+		q.mu.Unlock()
+		if remaining > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// 从自己的队列取出状态，队列为空时从其他协程的队列窃取一批，返回nil表示都没有状态
+func (f *searchFrontier) take(worker int) *StateItem {
+	own := &f.queues[worker]
+	own.mu.Lock()
+	if own.queue.Len() > 0 {
+		item := heap.Pop(&own.queue).(*StateItem)
+		own.mu.Unlock()
+		return item
+	}
+	own.mu.Unlock()
+
+	for i := 1; i < len(f.queues); i++ {
+		victim := &f.queues[(worker+i)%len(f.queues)]
+		victim.mu.Lock()
+		if victim.queue.Len() == 0 {
+			victim.mu.Unlock()
+			continue
+		}
+		// 窃取一半（最多stealBatch个），减少再次窃取的次数
+		stolen := make([]*StateItem, min(stealBatch, (victim.queue.Len()+1)/2))
+		for j := range stolen {
+			stolen[j] = heap.Pop(&victim.queue).(*StateItem)
+		}
+		victim.mu.Unlock()
+
+		own.mu.Lock()
+		for _, item := range stolen[1:] {
+			heap.Push(&own.queue, item)
+		}
+		own.mu.Unlock()
+		return stolen[0]
+	}
+	return nil
+}
+
+// 停止搜索：正在扩展的状态处理完后所有协程退出
+func (f *searchFrontier) stop() {
+	f.stopped.Store(true)
+	f.notify(true)
+}
+
+// 搜索是否被提前停止（队列中还有没扩展的状态）
+func (f *searchFrontier) interrupted() bool {
+	return f.stopped.Load() && f.hasQueued()
+}
+
+// 扩展完一个状态
+func (f *searchFrontier) done() {
+	if atomic.AddInt64(&f.pending, -1) == 0 {
+		f.notify(true)
+	}
+}
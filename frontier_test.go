@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// 被前沿中的状态在各项上都支配的状态不加入前沿，支配前沿中状态的新状态淘汰它们
+func TestFrontierRelaxDropsDominated(t *testing.T) {
+	f := newSearchFrontier(1, 1)
+	key := StateKey{Area: 1}
+	state := func(hp, mp int16) *State {
+		return &State{HP: hp, MP: mp, ATK: 10, DEF: 6, CollectedTreasures: NewExtendedBitSet(8)}
+	}
+	first, dominated, other := state(100, 10), state(90, 5), state(80, 20)
+	f.relax(0, key, first)
+	f.relax(0, key, dominated)
+	f.relax(0, key, other)
+	if f.isCurrent(key, dominated) || !f.isCurrent(key, first) || !f.isCurrent(key, other) {
+		t.Fatal("各项都更差的状态应该被丢弃，互不支配的状态都应保留")
+	}
+	better := state(120, 10)
+	f.relax(0, key, better)
+	if f.isCurrent(key, first) || !f.isCurrent(key, better) || !f.isCurrent(key, other) {
+		t.Error("新状态应该淘汰被它支配的状态")
+	}
+	if frontier := f.shard(key).states[key]; len(frontier) != 2 {
+		t.Errorf("前沿中有%d个状态, 期望2个", len(frontier))
+	}
+}
+
+// 没有状态可取的协程等待其他协程：有新状态时被唤醒取走它，所有状态扩展完或搜索被停止时返回false
+func TestFrontierIdleWorkerWaits(t *testing.T) {
+	popped := func(f *searchFrontier) chan bool {
+		result := make(chan bool, 1)
+		go func() {
+			_, ok := f.pop(1)
+			result <- ok
+		}()
+		return result
+	}
+	expect := func(result chan bool, want bool, what string) {
+		t.Helper()
+		select {
+		case ok := <-result:
+			if ok != want {
+				t.Errorf("%s: 取到状态=%v, 期望%v", what, ok, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: 等待的协程没有被唤醒", what)
+		}
+	}
+
+	f := newSearchFrontier(1, 2)
+	f.relax(0, StateKey{Area: 1}, &State{HP: 100, CollectedTreasures: NewExtendedBitSet(8)})
+	if _, ok := f.pop(0); !ok {
+		t.Fatal("应该取到状态")
+	}
+	result := popped(f)
+	select {
+	case <-result:
+		t.Fatal("其他协程正在扩展状态时不应返回")
+	case <-time.After(50 * time.Millisecond):
+	}
+	f.relax(0, StateKey{Area: 2}, &State{HP: 90, CollectedTreasures: NewExtendedBitSet(8)})
+	expect(result, true, "有新状态")
+
+	f.done()
+	result = popped(f)
+	f.done()
+	expect(result, false, "所有状态扩展完")
+
+	f = newSearchFrontier(1, 2)
+	f.relax(0, StateKey{Area: 1}, &State{HP: 100, CollectedTreasures: NewExtendedBitSet(8)})
+	f.pop(0)
+	result = popped(f)
+	f.stop()
+	expect(result, false, "搜索被停止")
+}
+
+// 并行搜索的扩展性：分别在GOMAXPROCS=1和GOMAXPROCS=CPU核数下用同样多的协程精确搜索
+func BenchmarkParallelSearch(b *testing.B) {
+	initDamageCache()
+	c := sampleCases[2]
+	graph := convertForTest(b, c.m, c.start, c.end)
+	procsList := []int{1}
+	if n := runtime.NumCPU(); n > 1 {
+		procsList = append(procsList, n)
+	}
+	for _, procs := range procsList {
+		b.Run(fmt.Sprintf("GOMAXPROCS=%d", procs), func(b *testing.B) {
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+			for i := 0; i < b.N; i++ {
+				searchSample(b, graph, c, &SearchOptions{Prune: &PruneConfig{Exact: true}, Workers: procs})
+			}
+		})
+	}
+}
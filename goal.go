@@ -0,0 +1,292 @@
+package main
+
+import "fmt"
+
+// 目标条件类型
+const (
+	goalReach   = "reach"   // 能到达Floor层Pos所在的区域
+	goalDefeat  = "defeat"  // 击败Floor层Pos处的连接点（怪物、门、NPC等）
+	goalCollect = "collect" // 拿到编号为Item的宝物（treasureMap中的编号，任意一个即可）
+	goalEquip   = "equip"   // 穿戴名为Name的装备（地图上必须有这件装备）
+	goalStat    = "stat"    // 指标Metric（见objective.go）不低于Value
+	goalBoss    = "boss"    // 以最终攻防能打赢怪物Monster（monsterMap中的编号）
+	goalAll     = "all"     // Goals全部满足
+	goalAny     = "any"     // Goals满足任意一个
+)
+
+// 搜索的终点条件，可以用all/any组合
+type Goal struct {
+	Kind    string
+	Floor   int
+	Pos     [2]int
+	Item    int
+	Name    string
+	Metric  string
+	Value   int
+	Monster int
+	Goals   []*Goal
+}
+
+// 到达指定位置
+func ReachGoal(pos [2]int) *Goal {
+	return &Goal{Kind: goalReach, Pos: pos}
+}
+
+// 击败指定位置的怪物
+func DefeatGoal(pos [2]int) *Goal {
+	return &Goal{Kind: goalDefeat, Pos: pos}
+}
+
+// 拿到指定编号的宝物
+func CollectGoal(item int) *Goal {
+	return &Goal{Kind: goalCollect, Item: item}
+}
+
+// 指标不低于给定值
+func StatGoal(metric string, min int) *Goal {
+	return &Goal{Kind: goalStat, Metric: metric, Value: min}
+}
+
+// 能打赢Boss
+func BossGoal(monsterID int) *Goal {
+	return &Goal{Kind: goalBoss, Monster: monsterID}
+}
+
+// 全部满足
+func AllGoals(goals ...*Goal) *Goal {
+	return &Goal{Kind: goalAll, Goals: goals}
+}
+
+// 满足任意一个
+func AnyGoal(goals ...*Goal) *Goal {
+	return &Goal{Kind: goalAny, Goals: goals}
+}
+
+// 目标引用的地图位置（楼层, x, y），化简图时需要保留
+func (goal *Goal) positions() [][3]int {
+	if goal == nil {
+		return nil
+	}
+	switch goal.Kind {
+	case goalReach, goalDefeat:
+		return [][3]int{{goal.Floor, goal.Pos[0], goal.Pos[1]}}
+	}
+	positions := [][3]int{}
+	for _, child := range goal.Goals {
+		positions = append(positions, child.positions()...)
+	}
+	return positions
+}
+
+// 解析到图上的目标条件
+type goalCheck struct {
+	kind      string
+	areas     map[int]bool  // reach：目标位置所在的区域
+	gateID    int           // defeat：连接点ID
+	treasures []int         // collect：宝物的全局下标
+	items     []int         // collect：可选拾取的物品所在的连接点ID
+	name      string        // equip
+	term      ObjectiveTerm // stat
+	value     int           // stat
+	monster   int           // boss
+	children  []*goalCheck  // all/any
+}
+
+// 检查目标能否在图上解析
+func (g *Graph) ValidateGoal(goal *Goal) error {
+	_, err := g.compileGoal(goal, g.globalTreasures())
+	return err
+}
+
+// 把目标中的位置和宝物编号解析为区域、连接点和宝物下标
+func (g *Graph) compileGoal(goal *Goal, allTreasures []*GlobalTreasure) (*goalCheck, error) {
+	check := &goalCheck{kind: goal.Kind}
+	switch goal.Kind {
+	case goalReach:
+		areaID := g.AreaAt(goal.Floor, goal.Pos)
+		if areaID < 0 {
+			// 楼梯等格子本身不属于区域时，取相邻的区域
+			gate := g.GateAt(goal.Floor, goal.Pos)
+			if gate == nil {
+				return nil, fmt.Errorf("位置%d:%v不在任何区域中", goal.Floor, goal.Pos)
+			}
+			check.areas = make(map[int]bool)
+			for _, id := range gate.Areas {
+				check.areas[id] = true
+			}
+			break
+		}
+		check.areas = map[int]bool{areaID: true}
+	case goalDefeat:
+		gate := g.gateContaining(goal.Floor, goal.Pos)
+		if gate == nil {
+			return nil, fmt.Errorf("位置%d:%v没有怪物", goal.Floor, goal.Pos)
+		}
+		check.gateID = gate.ID
+	case goalCollect:
+		for idx, treasure := range allTreasures {
+			if treasure.OriginalID == goal.Item {
+				check.treasures = append(check.treasures, idx)
+			}
+		}
+		for _, gate := range g.Gates {
+			if gate.Item != nil && gate.MonsterID == goal.Item {
+				check.items = append(check.items, gate.ID)
+			}
+		}
+		if len(check.treasures) == 0 && len(check.items) == 0 {
+			return nil, fmt.Errorf("地图上没有宝物%d", goal.Item)
+		}
+	case goalEquip:
+		check.name = goal.Name
+		found := false
+		for _, treasure := range allTreasures {
+			if treasure.Equip != nil && treasure.Equip.Name == goal.Name {
+				found = true
+			}
+		}
+		for _, gate := range g.Gates {
+			if gate.Item != nil && gate.Item.Equip != nil && gate.Item.Equip.Name == goal.Name {
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("地图上没有装备%q", goal.Name)
+		}
+	case goalStat:
+		check.term, check.value = ObjectiveTerm{Metric: goal.Metric, Weight: 1, Monster: goal.Monster}, goal.Value
+		if err := validateTerms([]ObjectiveTerm{check.term}); err != nil {
+			return nil, err
+		}
+	case goalBoss:
+		if _, exists := monsterMap[goal.Monster]; !exists {
+			return nil, fmt.Errorf("Boss%d不存在", goal.Monster)
+		}
+		check.monster = goal.Monster
+	case goalAll, goalAny:
+		if len(goal.Goals) == 0 {
+			return nil, fmt.Errorf("%s条件至少需要一个子条件", goal.Kind)
+		}
+		for _, child := range goal.Goals {
+			childCheck, err := g.compileGoal(child, allTreasures)
+			if err != nil {
+				return nil, err
+			}
+			check.children = append(check.children, childCheck)
+		}
+	default:
+		return nil, fmt.Errorf("未知的目标条件: %s", goal.Kind)
+	}
+	return check, nil
+}
+
+// 原来的终点条件：到达终点区域，攻防、魔防和钥匙不低于要求
+func heroGoal(required *HeroItem) *goalCheck {
+	stat := func(metric string, value int) *goalCheck {
+		return &goalCheck{kind: goalStat, term: ObjectiveTerm{Metric: metric, Weight: 1}, value: value}
+	}
+	return &goalCheck{kind: goalAll, children: []*goalCheck{
+		{kind: goalReach, areas: map[int]bool{required.AreaID: true}},
+		stat(metricATK, int(required.ATK)),
+		stat(metricDEF, int(required.DEF)),
+		stat(metricMDEF, int(required.MDEF)),
+		stat(metricYellowKeys, int(required.YellowKeys)),
+		stat(metricBlueKeys, int(required.BlueKeys)),
+	}}
+}
+
+// 状态是否满足目标，accessible为状态当前能到达的区域
+func (c *goalCheck) met(state *State, accessible map[int]bool, gates []*Gate) bool {
+	switch c.kind {
+	case goalReach:
+		for areaID := range c.areas {
+			if accessible[areaID] {
+				return true
+			}
+		}
+		return false
+	case goalDefeat:
+		return state.DefeatedMonsters.IsSet(c.gateID)
+	case goalCollect:
+		for _, idx := range c.treasures {
+			if state.CollectedTreasures.IsSet(idx) {
+				return true
+			}
+		}
+		for _, gateID := range c.items {
+			if state.DefeatedMonsters.IsSet(gateID) {
+				return true
+			}
+		}
+		return false
+	case goalEquip:
+		for _, item := range state.Equipped {
+			if item != nil && item.Name == c.name {
+				return true
+			}
+		}
+		return false
+	case goalStat:
+		return c.term.value(state, gates) >= c.value
+	case goalBoss:
+		damage, beatable := bossDamage(state.TotalATK(), state.TotalDEF(), state.MP, c.monster)
+		return beatable && state.HP > damage
+	case goalAll:
+		for _, child := range c.children {
+			if !child.met(state, accessible, gates) {
+				return false
+			}
+		}
+		return true
+	case goalAny:
+		for _, child := range c.children {
+			if child.met(state, accessible, gates) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// 满足目标前一定要击败的连接点（用于估计血量上限，宁少勿多）
+func (c *goalCheck) mandatoryGates(g *Graph, startArea int) []int {
+	switch c.kind {
+	case goalReach:
+		if len(c.areas) == 1 {
+			for areaID := range c.areas {
+				return g.MandatoryGateIDs(startArea, areaID)
+			}
+		}
+	case goalDefeat:
+		return []int{c.gateID}
+	case goalAll:
+		seen := make(map[int]bool)
+		gateIDs := []int{}
+		for _, child := range c.children {
+			for _, gateID := range child.mandatoryGates(g, startArea) {
+				if !seen[gateID] {
+					seen[gateID] = true
+					gateIDs = append(gateIDs, gateID)
+				}
+			}
+		}
+		return gateIDs
+	}
+	return nil
+}
+
+// 满足目标一定要打赢的Boss（monsterMap中的编号），没有时返回0
+func (c *goalCheck) requiredBoss() int {
+	switch c.kind {
+	case goalBoss:
+		return c.monster
+	case goalAll:
+		for _, child := range c.children {
+			if boss := child.requiredBoss(); boss != 0 {
+				return boss
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestValidateGoal(t *testing.T) {
+	graph := convertForTest(t, sampleMap, [2]int{5, 3}, [2]int{0, 3})
+	cases := []struct {
+		goal  *Goal
+		valid bool
+	}{
+		{ReachGoal([2]int{0, 3}), true},
+		{DefeatGoal([2]int{1, 3}), true},
+		{AllGoals(ReachGoal([2]int{0, 3}), StatGoal(metricATK, 12)), true},
+		{AnyGoal(DefeatGoal([2]int{1, 3}), BossGoal(211)), true},
+		{ReachGoal([2]int{0, 0}), false},
+		{DefeatGoal([2]int{4, 1}), false},
+		{CollectGoal(-1), false},
+		{&Goal{Kind: goalEquip, Name: "银剑"}, true},
+		{&Goal{Kind: goalEquip, Name: "骑士剑"}, false},
+		{StatGoal("exp", 1), false},
+		{BossGoal(-1), false},
+		{AllGoals(), false},
+		{AnyGoal(ReachGoal([2]int{0, 3}), &Goal{Kind: "teleport"}), false},
+	}
+	for i, c := range cases {
+		if err := graph.ValidateGoal(c.goal); (err == nil) != c.valid {
+			t.Errorf("用例%d: ValidateGoal() = %v, 期望有效=%v", i, err, c.valid)
+		}
+	}
+}
+
+func TestGoalCombinators(t *testing.T) {
+	defeated := NewExtendedBitSet(8)
+	defeated.Set(2)
+	state := &State{HP: 100, ATK: 12, DEF: 6, DefeatedMonsters: defeated}
+	accessible := map[int]bool{0: true, 1: true}
+
+	reach := &goalCheck{kind: goalReach, areas: map[int]bool{1: true}}
+	unreached := &goalCheck{kind: goalReach, areas: map[int]bool{5: true}}
+	beaten := &goalCheck{kind: goalDefeat, gateID: 2}
+	strong := &goalCheck{kind: goalStat, term: ObjectiveTerm{Metric: metricATK, Weight: 1}, value: 13}
+	cases := []struct {
+		name  string
+		check *goalCheck
+		met   bool
+	}{
+		{"到达", reach, true},
+		{"未到达", unreached, false},
+		{"击败", beaten, true},
+		{"攻击不足", strong, false},
+		{"全部满足", &goalCheck{kind: goalAll, children: []*goalCheck{reach, beaten}}, true},
+		{"全部中有一个不满足", &goalCheck{kind: goalAll, children: []*goalCheck{reach, strong}}, false},
+		{"任意一个满足", &goalCheck{kind: goalAny, children: []*goalCheck{unreached, beaten}}, true},
+		{"都不满足", &goalCheck{kind: goalAny, children: []*goalCheck{unreached, strong}}, false},
+	}
+	for _, c := range cases {
+		if met := c.check.met(state, accessible, nil); met != c.met {
+			t.Errorf("%s: met() = %v, 期望%v", c.name, met, c.met)
+		}
+	}
+}
+
+// 任意一个出口都可以时，结果不比只能走其中一个出口差
+func TestSearchAnyGoal(t *testing.T) {
+	initDamageCache()
+	c := sampleCases[0]
+	graph := convertForTest(t, c.m, c.start, c.end)
+	exits := []*Goal{ReachGoal([2]int{0, 3}), DefeatGoal([2]int{1, 3})}
+	either := searchSample(t, graph, c, &SearchOptions{Prune: &PruneConfig{Exact: true}, Goal: AnyGoal(exits...)})
+	for _, exit := range exits {
+		single := searchSample(t, graph, c, &SearchOptions{Prune: &PruneConfig{Exact: true}, Goal: exit})
+		if single.HP > either.HP {
+			t.Errorf("单个出口%v HP=%d 比任意出口 HP=%d 好", exit, single.HP, either.HP)
+		}
+	}
+	both := searchSample(t, graph, c, &SearchOptions{Prune: &PruneConfig{Exact: true}, Goal: AllGoals(exits...)})
+	if both.HP > either.HP {
+		t.Errorf("全部出口 HP=%d 比任意出口 HP=%d 好", both.HP, either.HP)
+	}
+}
+
+// 打不过的Boss无论血量多少都不满足目标，boss_hp为最小值
+func TestUnbeatableBoss(t *testing.T) {
+	initDamageCache()
+	const boss = 214 // 防御12
+	if _, beatable := bossDamage(10, 6, 0, boss); beatable {
+		t.Fatal("攻击10打不过防御12的Boss")
+	}
+	state := &State{HP: math.MaxInt16, ATK: 10, DEF: 6, DefeatedMonsters: NewExtendedBitSet(8)}
+	if (&goalCheck{kind: goalBoss, monster: boss}).met(state, nil, nil) {
+		t.Error("打不过的Boss不应满足目标")
+	}
+	term := ObjectiveTerm{Metric: metricBossHP, Weight: 1, Monster: boss}
+	if value := term.value(state, nil); value != math.MinInt16 {
+		t.Errorf("boss_hp = %d, 期望%d", value, math.MinInt16)
+	}
+
+	damage, beatable := bossDamage(15, 7, 0, 211)
+	if !beatable {
+		t.Fatal("攻击15应该能打赢防御2的Boss")
+	}
+	state.ATK, state.DEF, state.HP = 15, 7, damage
+	if (&goalCheck{kind: goalBoss, monster: 211}).met(state, nil, nil) {
+		t.Error("血量等于伤害时不应满足目标")
+	}
+	state.HP++
+	if value := (ObjectiveTerm{Metric: metricBossHP, Weight: 1, Monster: 211}).value(state, nil); value != 1 {
+		t.Errorf("boss_hp = %d, 期望1", value)
+	}
+}
+
+func TestSearchBossGoal(t *testing.T) {
+	initDamageCache()
+	c := sampleCases[0]
+	graph := convertForTest(t, c.m, c.start, c.end)
+	for _, prune := range []*PruneConfig{{Exact: true}, pruneConfig} {
+		for _, boss := range []int{211, 217, 214} {
+			result := searchSample(t, graph, c, &SearchOptions{
+				Prune:     prune,
+				Goal:      AllGoals(ReachGoal(c.end), BossGoal(boss)),
+				Objective: defaultObjective.withBoss(boss),
+			})
+			damage, beatable := bossDamage(result.ATK, result.DEF, result.MP, boss)
+			if result.HP == -1 {
+				if beatable {
+					t.Errorf("Boss%d(精确=%v): 没有找到解", boss, prune.Exact)
+				}
+				continue
+			}
+			if !beatable || result.HP <= damage || result.Score[0] != int(result.HP)-int(damage) {
+				t.Errorf("Boss%d(精确=%v): HP=%d 伤害=%d 得分=%v", boss, prune.Exact, result.HP, damage, result.Score)
+			}
+		}
+	}
+}
+
+// 以Boss为目标时连续战斗剪枝的要求由Boss推算，不再是0（否则规则永远不会生效）
+func TestBossPruneTarget(t *testing.T) {
+	initDamageCache()
+	const boss = 214 // 生命166 攻击17 防御12
+	if atk, def := bossTarget(boss, 230); atk != 13 || def != 16 {
+		t.Errorf("230点血的目标: 攻击%d 防御%d, 期望攻击13（刚好破防） 防御16", atk, def)
+	}
+	if atk, def := bossTarget(boss, 1); atk != 13 || def != 17 {
+		t.Errorf("1点血的目标: 攻击%d 防御%d, 期望不掉血的防御17", atk, def)
+	}
+
+	state := &State{ATK: 10, DEF: 6, ConsecutiveFights: 5}
+	atk, def := bossTarget(boss, 230)
+	if !pruneConfig.shouldPrune(state, atk, def, 16) {
+		t.Error("连续战斗5次后攻防仍远低于Boss推算的要求，应该剪枝")
+	}
+
+	// 默认剪枝配置下的Boss模式：能找到打得赢的解，且不超过精确搜索的结果
+	c := sampleCases[2]
+	graph := convertForTest(t, c.m, c.start, c.end)
+	options := func(prune *PruneConfig) *SearchOptions {
+		return &SearchOptions{Prune: prune, Goal: AllGoals(ReachGoal(c.end), BossGoal(boss)), Objective: defaultObjective.withBoss(boss)}
+	}
+	pruned := searchSample(t, graph, c, options(pruneConfig))
+	exact := searchSample(t, graph, c, options(&PruneConfig{Exact: true}))
+	damage, beatable := bossDamage(pruned.ATK, pruned.DEF, pruned.MP, boss)
+	if pruned.HP == -1 || !beatable || pruned.HP <= damage {
+		t.Fatalf("默认剪枝: HP=%d 攻击%d 防御%d, 期望能打赢Boss", pruned.HP, pruned.ATK, pruned.DEF)
+	}
+	if compareScores(pruned.Score, exact.Score) > 0 {
+		t.Errorf("默认剪枝的得分%v超过了精确搜索的%v", pruned.Score, exact.Score)
+	}
+}
@@ -3,27 +3,33 @@ package main
 import (
 	"fmt"
 	"sort"
-	"strconv"
-	"strings"
 )
 
 type Area struct {
 	ID        int
+	Floor     int // 所在楼层（单层地图为0）
 	Treasures []*TreasureItem
-	Neighbors []*Neighbor
+	Gates     []int // 相邻的连接点ID（升序）
 	Positions [][2]int
+	// 单向通道或传送门在连接点处的落脚区域不占格子，只挨着Beside位置的连接点：
+	// Exit为false时在连接点前（到达后只能打败它继续前进），为true时在连接点后（打败它才能到达）
+	Beside *[2]int
+	Exit   bool
 }
 
-type Neighbor struct {
-	Area       int
-	MonsterID  int
-	Monster    *Monster
-	MonsterPos [2]int
+type BreakPoint struct {
+	Floor        int
+	Pos          [2]int
+	AreaIDs      []int    // 该破点能连接的区域ID
+	Gates        [][2]int // 破墙后新接触到的连接点位置（怪物、门等）
+	Alternatives [][2]int // 效果相同的其他墙的位置
+	Score        int      // 排序评分：连通的区域、新接触的连接点和区域内宝物越多越高
 }
 
-type BreakPoint struct {
-	Pos     [2]int
-	AreaIDs []int // 该破点能连接的区域ID
+// 区域之间无需战斗的有向连接（楼梯、传送门、单向通道等）
+type AreaLink struct {
+	From int
+	To   int
 }
 
 // 中心飞目标信息
@@ -42,16 +48,24 @@ type CenterFlyResult struct {
 
 // Graph 结构添加中心飞相关字段
 type Graph struct {
-	Areas              []*Area
-	StartArea          int
-	EndArea            int
-	AreaMap            [][]int
-	MonsterConnections map[string]*MonsterConnection
-	BreakPoints        []*BreakPoint
+	Areas         []*Area
+	StartArea     int
+	EndArea       int
+	AreaMap       [][]int
+	Gates         []*Gate // 按楼层和位置排序，下标即连接点ID
+	BreakPoints   []*BreakPoint
+	Links         []*AreaLink // 区域之间的直接连接
+	FloorAreaMaps [][][]int   // 多层塔中每层的区域映射（单层地图为nil）
+	StairAreas    [][]int     // 多层塔中每层楼梯所在的区域
+	Teleporter    *Teleporter // 楼层传送器（nil表示没有）
+	Triggers      []*Trigger  // 事件触发器（机关门、陷阱）
 
 	// 新增：中心飞相关
 	centerPos      [2]int                   // 地图中心坐标
 	centerFlyCache map[int]*CenterFlyResult // 按区域ID缓存的中心飞查询结果
+
+	source    *MapToGraphConverter // 生成该图的转换器（多层塔为nil），用于增量修改格子
+	gateIndex map[[3]int]int       // (楼层, x, y) -> 连接点ID
 }
 
 // 地图转图转换器
@@ -61,29 +75,45 @@ type MapToGraphConverter struct {
 	cols               int
 	treasureMap        map[int]*Treasure
 	monsterMap         map[int]*Monster
+	npcMap             map[int]*NPC
 	start              [2]int
 	end                [2]int
 	directions         [][2]int
 	areas              []*Area
-	monsterConnections map[string]map[int]bool
+	monsterConnections map[[2]int]map[int]bool // 连接点位置 -> 相邻的区域
+	portals            map[[2]int][2]int       // 传送门：踏上源格子后立即被传送到目标格子
+	triggers           []*Trigger
+	dynamicCells       map[[2]int]*DynamicCell // 状态由触发器决定的格子
 }
 
 // 创建新的转换器
-func NewMapToGraphConverter(gameMap [][]int, treasureMap map[int]*Treasure, monsterMap map[int]*Monster, start, end [2]int) *MapToGraphConverter {
+func NewMapToGraphConverter(gameMap [][]int, treasureMap map[int]*Treasure, monsterMap map[int]*Monster, npcMap map[int]*NPC, start, end [2]int) *MapToGraphConverter {
 	return &MapToGraphConverter{
 		gameMap:            gameMap,
 		rows:               len(gameMap),
 		cols:               len(gameMap[0]),
 		treasureMap:        treasureMap,
 		monsterMap:         monsterMap,
+		npcMap:             npcMap,
 		start:              start,
 		end:                end,
 		directions:         [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}},
 		areas:              []*Area{},
-		monsterConnections: make(map[string]map[int]bool),
+		monsterConnections: make(map[[2]int]map[int]bool),
 	}
 }
 
+// 设置传送门，portals为传送门格子到目标格子的映射
+func (c *MapToGraphConverter) SetPortals(portals map[[2]int][2]int) {
+	c.portals = portals
+}
+
+// 设置事件触发器，触发器涉及的格子会作为连接点处理
+func (c *MapToGraphConverter) SetTriggers(triggers []*Trigger) {
+	c.triggers = triggers
+	c.dynamicCells = dynamicCellsOf(triggers)
+}
+
 // 计算给定位置的中心对称点
 func (g *Graph) getCenterSymmetricPos(pos [2]int) [2]int {
 	return [2]int{
@@ -113,6 +143,143 @@ func (c *MapToGraphConverter) isValidPosition(pos [2]int) bool {
 	return x >= 0 && x < c.rows && y >= 0 && y < c.cols && c.gameMap[x][y] != 1
 }
 
+// 检查格子是否会阻挡通行（怪物、门、NPC、可选物品），这类格子单独作为连接点处理
+func (c *MapToGraphConverter) isBlockingCell(cellValue int) bool {
+	if _, exists := c.monsterMap[cellValue]; exists {
+		return true
+	}
+	if _, exists := c.npcMap[cellValue]; exists {
+		return true
+	}
+	treasure, exists := c.treasureMap[cellValue]
+	return exists && treasure.Optional
+}
+
+// 检查位置是否需要作为连接点处理（阻挡格子或动态格子）
+func (c *MapToGraphConverter) isGateAt(x, y int) bool {
+	_, dynamic := c.dynamicCells[[2]int{x, y}]
+	return dynamic || c.isBlockingCell(c.gameMap[x][y])
+}
+
+// 获取格子上的可选物品（自动拾取的宝物返回nil）
+func (c *MapToGraphConverter) optionalItemAt(cellValue int) *Treasure {
+	if treasure, exists := c.treasureMap[cellValue]; exists && treasure.Optional {
+		return treasure
+	}
+	return nil
+}
+
+// 检查格子是否为单向通道或传送门，这类格子不属于任何区域，只形成有向连接
+func (c *MapToGraphConverter) isPassageCell(cellValue int) bool {
+	_, isArrow := arrowDirections[cellValue]
+	return isArrow || cellValue == PortalID
+}
+
+// 获取位置所属的区域（越界或不属于任何区域时返回-1）
+func (c *MapToGraphConverter) areaAt(visited [][]int, x, y int) int {
+	if x < 0 || x >= c.rows || y < 0 || y >= c.cols {
+		return -1
+	}
+	return visited[x][y]
+}
+
+// 从进入的格子开始沿单向通道和传送门一直走，返回第一个不是通道的格子
+func (c *MapToGraphConverter) followPassage(cell [2]int) ([2]int, error) {
+	seen := make(map[[2]int]bool)
+	for {
+		if !c.isValidPosition(cell) {
+			return cell, fmt.Errorf("通道通向墙或地图外: %v", cell)
+		}
+		if seen[cell] {
+			return cell, fmt.Errorf("通道形成环路: %v", cell)
+		}
+		seen[cell] = true
+
+		cellValue := c.gameMap[cell[0]][cell[1]]
+		if dir, isArrow := arrowDirections[cellValue]; isArrow {
+			cell = [2]int{cell[0] + dir[0], cell[1] + dir[1]}
+		} else if cellValue == PortalID {
+			target, exists := c.portals[cell]
+			if !exists {
+				return cell, fmt.Errorf("传送门%v没有目标", cell)
+			}
+			cell = target
+		} else {
+			return cell, nil
+		}
+	}
+}
+
+// 构建单向通道和传送门形成的有向连接
+// 通道的起点或终点是连接点时，连接落在gateArea给出的该连接点旁的落脚区域上（exit表示在连接点后）
+func (c *MapToGraphConverter) buildPassageLinks(visited [][]int, gateArea func(pos [2]int, exit bool) int) ([]*AreaLink, error) {
+	links := []*AreaLink{}
+	seen := make(map[[2]int]bool)
+	addLink := func(from, to int) {
+		if from == to || seen[[2]int{from, to}] {
+			return
+		}
+		seen[[2]int{from, to}] = true
+		links = append(links, &AreaLink{From: from, To: to})
+	}
+	// 格子所在的区域，连接点取它旁边的落脚区域
+	areaOf := func(cell [2]int, exit bool) (int, error) {
+		if c.isGateAt(cell[0], cell[1]) {
+			return gateArea(cell, exit), nil
+		}
+		if areaID := c.areaAt(visited, cell[0], cell[1]); areaID != -1 {
+			return areaID, nil
+		}
+		return -1, fmt.Errorf("通道端点%v不属于任何区域", cell)
+	}
+	// 从entry进入通道，从from出发到达通道尽头
+	link := func(from, entry [2]int) error {
+		target, err := c.followPassage(entry)
+		if err != nil {
+			return err
+		}
+		fromArea, err := areaOf(from, true)
+		if err != nil {
+			return err
+		}
+		toArea, err := areaOf(target, false)
+		if err != nil {
+			return err
+		}
+		addLink(fromArea, toArea)
+		return nil
+	}
+
+	for i := 0; i < c.rows; i++ {
+		for j := 0; j < c.cols; j++ {
+			cellValue := c.gameMap[i][j]
+			if dir, isArrow := arrowDirections[cellValue]; isArrow {
+				// 从箭头后方进入，沿箭头一直走到通道尽头；后方是通道时由那段通道的起点处理
+				back := [2]int{i - dir[0], j - dir[1]}
+				if !c.isValidPosition(back) || c.isPassageCell(c.gameMap[back[0]][back[1]]) {
+					continue
+				}
+				if err := link(back, [2]int{i, j}); err != nil {
+					return nil, err
+				}
+			} else if cellValue == PortalID {
+				// 从传送门四周任一格子踏上传送门，都会到达目标格子
+				for _, dir := range c.directions {
+					from := [2]int{i + dir[0], j + dir[1]}
+					if !c.isValidPosition(from) || c.isPassageCell(c.gameMap[from[0]][from[1]]) {
+						continue
+					}
+					if err := link(from, [2]int{i, j}); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+
+	return links, nil
+}
+
 // 处理怪物位置，检查其连通性并处理相邻的未访问区域
 func (c *MapToGraphConverter) processMonsterPosition(x, y int, visited [][]int, areaCount *int, startArea, endArea *int) {
 	connectedAreas := make(map[int]bool)
@@ -129,8 +296,7 @@ func (c *MapToGraphConverter) processMonsterPosition(x, y int, visited [][]int,
 			connectedAreas[visited[nx][ny]] = true
 		} else {
 			// 邻居是未访问的位置
-			neighborVal := c.gameMap[nx][ny]
-			if _, exists := c.monsterMap[neighborVal]; !exists {
+			if !c.isGateAt(nx, ny) && !c.isPassageCell(c.gameMap[nx][ny]) {
 				// 邻居不是怪物，为它创建新区域
 				areaID := *areaCount
 				c.processCellAsNewArea(nx, ny, areaID, visited, startArea, endArea)
@@ -142,8 +308,7 @@ func (c *MapToGraphConverter) processMonsterPosition(x, y int, visited [][]int,
 
 	// 记录怪物连接信息
 	if len(connectedAreas) >= 1 {
-		key := fmt.Sprintf("%d,%d", x, y)
-		c.monsterConnections[key] = connectedAreas
+		c.monsterConnections[[2]int{x, y}] = connectedAreas
 	}
 }
 
@@ -154,7 +319,7 @@ func (c *MapToGraphConverter) processCellAsNewArea(x, y, areaID int, visited [][
 	area := &Area{
 		ID:        areaID,
 		Treasures: []*TreasureItem{},
-		Neighbors: []*Neighbor{},
+		Gates:     []int{},
 		Positions: [][2]int{},
 	}
 
@@ -179,6 +344,7 @@ func (c *MapToGraphConverter) processCellAsNewArea(x, y, areaID int, visited [][
 				ID:         len(area.Treasures),
 				Type:       treasure.Type,
 				Value:      treasure.Value,
+				Equip:      treasure.Equip,
 				OriginalID: cellVal,
 			})
 		}
@@ -190,8 +356,7 @@ func (c *MapToGraphConverter) processCellAsNewArea(x, y, areaID int, visited [][
 				continue
 			}
 
-			neighborVal := c.gameMap[nx][ny]
-			if _, exists := c.monsterMap[neighborVal]; !exists {
+			if !c.isGateAt(nx, ny) && !c.isPassageCell(c.gameMap[nx][ny]) {
 				// 非怪物位置，加入当前区域
 				visited[nx][ny] = areaID
 				queue = append(queue, [2]int{nx, ny})
@@ -203,49 +368,115 @@ func (c *MapToGraphConverter) processCellAsNewArea(x, y, areaID int, visited [][
 	c.areas = append(c.areas, area)
 }
 
-// 构建怪物连接信息
-func (c *MapToGraphConverter) buildMonsterConnections(visited [][]int) map[string]*MonsterConnection {
-	monsterConnections := make(map[string]*MonsterConnection)
-
-	for monsterPos, areas := range c.monsterConnections {
+// 构建连接点（ID由Graph.indexGates统一分配）
+func (c *MapToGraphConverter) buildGates() []*Gate {
+	gates := make([]*Gate, 0, len(c.monsterConnections))
+	for pos, areas := range c.monsterConnections {
 		areaList := make([]int, 0, len(areas))
 		for area := range areas {
 			areaList = append(areaList, area)
 		}
+		sort.Ints(areaList)
+		gates = append(gates, c.newGate(pos[0], pos[1], areaList))
+	}
+	return gates
+}
+
+// 创建格子上的连接点
+func (c *MapToGraphConverter) newGate(x, y int, areaList []int) *Gate {
+	monsterID := c.gameMap[x][y]
+	return &Gate{
+		MonsterID: monsterID,
+		Monster:   c.monsterMap[monsterID],
+		NPC:       c.npcMap[monsterID],
+		Item:      c.optionalItemAt(monsterID),
+		Dynamic:   c.dynamicCells[[2]int{x, y}],
+		Pos:       [2]int{x, y},
+		Areas:     areaList,
+	}
+}
 
-		if len(areaList) >= 1 { // 修改：至少连接1个区域就有意义
-			parts := strings.Split(monsterPos, ",")
-			x, _ := strconv.Atoi(parts[0])
-			y, _ := strconv.Atoi(parts[1])
-			monsterID := c.gameMap[x][y]
-			monster := c.monsterMap[monsterID]
+// 收集破墙点：拆掉后能连通至少两个区域或连接点（怪物、门等）的墙
+// 效果完全相同的墙只保留一个，其余位置记录在Alternatives中；结果按Score从高到低排序
+func (c *MapToGraphConverter) collectBreakPoints(visited [][]int, areas []*Area) []*BreakPoint {
+	breakPointMap := make(map[string]*BreakPoint)
+	breakPoints := []*BreakPoint{}
+
+	for i := 0; i < c.rows; i++ {
+		for j := 0; j < c.cols; j++ {
+			if c.gameMap[i][j] != 1 {
+				continue // 只考虑墙
+			}
+			neighborAreas := map[int]bool{}
+			gateCells := [][2]int{}
+			for _, dir := range c.directions {
+				nx, ny := i+dir[0], j+dir[1]
+				if nx < 0 || nx >= c.rows || ny < 0 || ny >= c.cols {
+					continue // 地图外没有格子：边缘上的墙只按地图内的邻居判断，照样可以是破墙点
+				}
+				if aid := visited[nx][ny]; aid != -1 {
+					neighborAreas[aid] = true
+				} else if c.isValidPosition([2]int{nx, ny}) && c.isGateAt(nx, ny) {
+					gateCells = append(gateCells, [2]int{nx, ny})
+				}
+			}
 
-			// 创建怪物连接信息
-			monsterConnections[monsterPos] = &MonsterConnection{
-				MonsterID:      monsterID,
-				Monster:        monster,
-				MonsterPos:     [2]int{x, y},
-				ConnectedAreas: areaList,
+			areaList := []int{}
+			for aid := range neighborAreas {
+				areaList = append(areaList, aid)
 			}
+			sort.Ints(areaList)
 
-			// 为每个相关区域添加邻居引用
-			for _, areaID := range areaList {
-				neighbor := &Neighbor{
-					Area:       -1, // 特殊标记，表示这是一个多区域连接
-					MonsterID:  monsterID,
-					Monster:    monster,
-					MonsterPos: [2]int{x, y},
+			// 只记录原本不和这些区域相连的连接点
+			gates := [][2]int{}
+			for _, gate := range gateCells {
+				if !c.gateTouchesAny(visited, gate, neighborAreas) {
+					gates = append(gates, gate)
 				}
-				c.areas[areaID].Neighbors = append(c.areas[areaID].Neighbors, neighbor)
 			}
+			if len(areaList) < 2 && (len(gates) == 0 || len(areaList)+len(gates) < 2) {
+				continue
+			}
+
+			// 生成区域和连接点组合key用于去重
+			key := fmt.Sprintf("%v%v", areaList, gates)
+			if existing, exists := breakPointMap[key]; exists {
+				existing.Alternatives = append(existing.Alternatives, [2]int{i, j})
+				continue
+			}
+			bp := &BreakPoint{
+				Pos:          [2]int{i, j},
+				AreaIDs:      areaList,
+				Gates:        gates,
+				Alternatives: [][2]int{},
+			}
+			bp.Score = 2*(len(areaList)-1) + len(gates)
+			for _, aid := range areaList {
+				bp.Score += len(areas[aid].Treasures)
+			}
+			breakPointMap[key] = bp
+			breakPoints = append(breakPoints, bp)
 		}
 	}
 
-	return monsterConnections
+	sort.SliceStable(breakPoints, func(a, b int) bool {
+		return breakPoints[a].Score > breakPoints[b].Score
+	})
+	return breakPoints
+}
+
+// 连接点是否和给定区域中的任一个相邻
+func (c *MapToGraphConverter) gateTouchesAny(visited [][]int, gate [2]int, areaSet map[int]bool) bool {
+	for _, dir := range c.directions {
+		if aid := c.areaAt(visited, gate[0]+dir[0], gate[1]+dir[1]); aid != -1 && areaSet[aid] {
+			return true
+		}
+	}
+	return false
 }
 
 // 修改MapToGraphConverter的Convert方法，添加中心飞缓存构建
-func (c *MapToGraphConverter) Convert() *Graph {
+func (c *MapToGraphConverter) Convert() (*Graph, error) {
 	visited := make([][]int, c.rows)
 	for i := range visited {
 		visited[i] = make([]int, c.cols)
@@ -263,13 +494,13 @@ func (c *MapToGraphConverter) Convert() *Graph {
 		for j := 0; j < c.cols; j++ {
 			cellValue := c.gameMap[i][j]
 
-			// 跳过墙和已访问位置
-			if visited[i][j] != -1 || cellValue == 1 {
+			// 跳过墙、已访问位置和单向通道/传送门
+			if visited[i][j] != -1 || cellValue == 1 || c.isPassageCell(cellValue) {
 				continue
 			}
 
 			// 关键修改：特殊处理怪物位置
-			if _, exists := c.monsterMap[cellValue]; exists {
+			if c.isGateAt(i, j) {
 				// 怪物位置：检查连通性并处理相邻区域
 				c.processMonsterPosition(i, j, visited, &areaCount, &startArea, &endArea)
 				continue
@@ -282,67 +513,84 @@ func (c *MapToGraphConverter) Convert() *Graph {
 		}
 	}
 
-	// 第二遍：构建最终的怪物连接信息
-	monsterConnections := c.buildMonsterConnections(visited)
-
-	// 收集破墙点
-	breakPointMap := make(map[string]*BreakPoint)
-
-	for i := 0; i < c.rows; i++ {
-		for j := 0; j < c.cols; j++ {
-			if c.gameMap[i][j] != 1 {
-				continue // 只考虑墙
-			}
-			neighborAreas := map[int]bool{}
-			for _, dir := range c.directions {
-				nx, ny := i+dir[0], j+dir[1]
-				if nx < 0 || nx >= c.rows || ny < 0 || ny >= c.cols {
-					continue
-				}
-				aid := visited[nx][ny]
-				if aid != -1 {
-					neighborAreas[aid] = true
-				}
-			}
-			if len(neighborAreas) >= 2 {
-				// 生成区域组合key用于去重
-				areaList := []int{}
-				for aid := range neighborAreas {
-					areaList = append(areaList, aid)
-				}
-				sort.Ints(areaList)
-				key := fmt.Sprintf("%v", areaList)
-				if _, exists := breakPointMap[key]; !exists {
-					breakPointMap[key] = &BreakPoint{
-						Pos:     [2]int{i, j},
-						AreaIDs: areaList,
-					}
-				}
-			}
+	// 第二遍：构建单向通道和传送门的连接，通道端点是连接点时加上落脚区域，再构建最终的连接点
+	besideAreas := make(map[[3]int]int)
+	links, err := c.buildPassageLinks(visited, func(pos [2]int, exit bool) int {
+		if areaID, exists := besideAreas[besideKey(pos, exit)]; exists {
+			return areaID
 		}
+		areaID := len(c.areas)
+		c.areas = append(c.areas, newBesideArea(areaID, pos, exit))
+		if c.monsterConnections[pos] == nil {
+			c.monsterConnections[pos] = make(map[int]bool)
+		}
+		c.monsterConnections[pos][areaID] = true
+		besideAreas[besideKey(pos, exit)] = areaID
+		return areaID
+	})
+	if err != nil {
+		return nil, err
 	}
-	breakPoints := []*BreakPoint{}
-	for _, bp := range breakPointMap {
-		breakPoints = append(breakPoints, bp)
+	err = checkTriggers(c.triggers, func(_ *Trigger, pos [2]int) bool {
+		_, exists := c.monsterConnections[pos]
+		return exists
+	})
+	if err != nil {
+		return nil, err
+	}
+	gates := c.buildGates()
+	err = checkNPCRequirements(gates, func(_ int, pos [2]int) bool {
+		_, exists := c.monsterConnections[pos]
+		return exists
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	// 收集破墙点
+	breakPoints := c.collectBreakPoints(visited, c.areas)
+
 	// 验证转换结果
 	//c.validateConversion()
 
 	// 创建Graph
 	graph := &Graph{
-		Areas:              c.areas,
-		StartArea:          startArea,
-		EndArea:            endArea,
-		AreaMap:            visited,
-		MonsterConnections: monsterConnections,
-		BreakPoints:        breakPoints,
+		Areas:       c.areas,
+		StartArea:   startArea,
+		EndArea:     endArea,
+		AreaMap:     visited,
+		Gates:       gates,
+		BreakPoints: breakPoints,
+		Links:       links,
+		Triggers:    c.triggers,
+		source:      c,
 	}
+	graph.indexGates()
 
 	// 构建中心飞缓存
 	graph.buildCenterFlyCache(c.gameMap)
 	//ExampleCenterFlyUsage(graph)
-	return graph
+	return graph, nil
+}
+
+// 落脚区域的索引：连接点位置和是否在连接点后
+func besideKey(pos [2]int, exit bool) [3]int {
+	if exit {
+		return [3]int{pos[0], pos[1], 1}
+	}
+	return [3]int{pos[0], pos[1], 0}
+}
+
+// 在连接点旁加一个不占格子的落脚区域
+func newBesideArea(areaID int, pos [2]int, exit bool) *Area {
+	return &Area{
+		ID:        areaID,
+		Treasures: []*TreasureItem{},
+		Gates:     []int{},
+		Positions: [][2]int{},
+		Beside:    &pos,
+		Exit:      exit,
+	}
 }
 
 // 验证转换结果
@@ -415,6 +663,114 @@ func (g *Graph) buildCenterFlyCache(gameMap [][]int) {
 	}
 }
 
+// 检查是否存在单向连接（有向连接没有对应的反向连接）
+func (g *Graph) HasOneWayLinks() bool {
+	linkSet := make(map[[2]int]bool)
+	for _, link := range g.Links {
+		linkSet[[2]int{link.From, link.To}] = true
+	}
+	for _, link := range g.Links {
+		if !linkSet[[2]int{link.To, link.From}] {
+			return true
+		}
+	}
+	return false
+}
+
+// 按楼层和位置排序连接点并分配ID，重建每个区域的连接点列表
+// 连接点和区域都会复制一份，不影响共享它们的其他图
+func (g *Graph) indexGates() {
+	sort.Slice(g.Gates, func(i, j int) bool {
+		a, b := g.Gates[i], g.Gates[j]
+		if a.Floor != b.Floor {
+			return a.Floor < b.Floor
+		}
+		if a.Pos[0] != b.Pos[0] {
+			return a.Pos[0] < b.Pos[0]
+		}
+		return a.Pos[1] < b.Pos[1]
+	})
+
+	areas := make([]*Area, len(g.Areas))
+	for i, area := range g.Areas {
+		copied := *area
+		copied.Gates = []int{}
+		areas[i] = &copied
+	}
+
+	gates := make([]*Gate, len(g.Gates))
+	g.gateIndex = make(map[[3]int]int, len(g.Gates))
+	for i, gate := range g.Gates {
+		copied := *gate
+		copied.ID = i
+		copied.Areas = append([]int{}, gate.Areas...)
+		gates[i] = &copied
+		g.gateIndex[[3]int{gate.Floor, gate.Pos[0], gate.Pos[1]}] = i
+		for _, areaID := range copied.Areas {
+			areas[areaID].Gates = append(areas[areaID].Gates, i)
+		}
+	}
+
+	g.Gates = gates
+	g.Areas = areas
+}
+
+// 区域相邻的连接点
+func (g *Graph) GatesOf(areaID int) []*Gate {
+	gates := make([]*Gate, 0, len(g.Areas[areaID].Gates))
+	for _, gateID := range g.Areas[areaID].Gates {
+		gates = append(gates, g.Gates[gateID])
+	}
+	return gates
+}
+
+// 连接点相邻的区域
+func (g *Graph) AreasOf(gateID int) []*Area {
+	areas := make([]*Area, 0, len(g.Gates[gateID].Areas))
+	for _, areaID := range g.Gates[gateID].Areas {
+		areas = append(areas, g.Areas[areaID])
+	}
+	return areas
+}
+
+// 查找指定楼层位置上的连接点（没有时返回nil）
+func (g *Graph) GateAt(floor int, pos [2]int) *Gate {
+	if gateID, exists := g.gateIndex[[3]int{floor, pos[0], pos[1]}]; exists {
+		return g.Gates[gateID]
+	}
+	return nil
+}
+
+// 包含指定楼层位置的连接点：该位置本身是连接点，或是走廊怪物链中的一员（没有时返回nil）
+func (g *Graph) gateContaining(floor int, pos [2]int) *Gate {
+	if gate := g.GateAt(floor, pos); gate != nil {
+		return gate
+	}
+	for _, gate := range g.Gates {
+		for _, member := range gate.Chain {
+			if member.Floor == floor && member.Pos == pos {
+				return gate
+			}
+		}
+	}
+	return nil
+}
+
+// 指定楼层位置所在的区域ID（墙、连接点等不属于区域的格子返回-1）
+func (g *Graph) AreaAt(floor int, pos [2]int) int {
+	areaMap := g.AreaMap
+	if g.FloorAreaMaps != nil {
+		if floor < 0 || floor >= len(g.FloorAreaMaps) {
+			return -1
+		}
+		areaMap = g.FloorAreaMaps[floor]
+	}
+	if pos[0] < 0 || pos[0] >= len(areaMap) || pos[1] < 0 || pos[1] >= len(areaMap[pos[0]]) {
+		return -1
+	}
+	return areaMap[pos[0]][pos[1]]
+}
+
 // 查询从指定区域出发的所有中心飞目标
 func (g *Graph) GetCenterFlyTargets(fromAreaID int) *CenterFlyResult {
 	if result, exists := g.centerFlyCache[fromAreaID]; exists {
@@ -0,0 +1,179 @@
+package main
+
+import "testing"
+
+// 地图边缘上的墙只有地图内的邻居，连通两个区域时也是破墙点
+func TestBreakPointsAtMapEdge(t *testing.T) {
+	m := [][]int{
+		{0, 1, 0},
+		{1, 1, 1},
+	}
+	graph, err := NewMapToGraphConverter(m, treasureMap, monsterMap, npcMap, [2]int{0, 0}, [2]int{0, 2}).Convert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.BreakPoints) != 1 {
+		t.Fatalf("破墙点数 = %d, 期望1", len(graph.BreakPoints))
+	}
+	bp := graph.BreakPoints[0]
+	if bp.Pos != [2]int{0, 1} || len(bp.AreaIDs) != 2 {
+		t.Errorf("破墙点 = %v %v, 期望(0,1)连通两个区域", bp.Pos, bp.AreaIDs)
+	}
+}
+
+// 墙的另一侧是怪物时，拆墙后新接触到的怪物也算连接
+func TestBreakPointsNextToGate(t *testing.T) {
+	m := [][]int{
+		{0, 1, 210, 0},
+		{1, 1, 1, 1},
+	}
+	graph, err := NewMapToGraphConverter(m, treasureMap, monsterMap, npcMap, [2]int{0, 0}, [2]int{0, 3}).Convert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.BreakPoints) != 1 {
+		t.Fatalf("破墙点数 = %d, 期望1", len(graph.BreakPoints))
+	}
+	bp := graph.BreakPoints[0]
+	if bp.Pos != [2]int{0, 1} || len(bp.Gates) != 1 || bp.Gates[0] != [2]int{0, 2} {
+		t.Errorf("破墙点 = %v 连接点%v, 期望(0,1)接触(0,2)的怪物", bp.Pos, bp.Gates)
+	}
+}
+
+// 连续的箭头一直走到第一个不是通道的格子
+func TestArrowRunEndsOnGate(t *testing.T) {
+	initDamageCache()
+	m := [][]int{
+		{1, 1, 1, 1, 1, 1, 1, 1},
+		{1, 0, 164, 164, 210, 0, 0, 1},
+		{1, 1, 1, 1, 1, 1, 1, 1},
+	}
+	graph := convertForTest(t, m, [2]int{1, 1}, [2]int{1, 6})
+	if len(graph.Links) != 1 {
+		t.Fatalf("单向连接数 = %d, 期望1", len(graph.Links))
+	}
+	landing := graph.Areas[graph.Links[0].To]
+	if landing.Beside == nil || *landing.Beside != [2]int{1, 4} || landing.Exit {
+		t.Errorf("通道终点 = %+v, 期望(1,4)怪物前的落脚区域", landing)
+	}
+	result := findOptimalPath(graph, &HeroItem{HP: 400, ATK: 10, DEF: 6, AreaID: graph.StartArea}, &HeroItem{AreaID: graph.EndArea})
+	if result.HP != 400-getDamage(10, 6, 210) {
+		t.Errorf("HP = %d, 期望打过(1,4)的怪物后到达终点", result.HP)
+	}
+}
+
+// 怪物后面接箭头：打败怪物才能进入通道，反方向走不通
+func TestArrowRunStartsBehindGate(t *testing.T) {
+	initDamageCache()
+	m := [][]int{
+		{1, 1, 1, 1, 1, 1, 1},
+		{1, 0, 210, 164, 164, 0, 1},
+		{1, 1, 1, 1, 1, 1, 1},
+	}
+	forward := convertForTest(t, m, [2]int{1, 1}, [2]int{1, 5})
+	result := findOptimalPath(forward, &HeroItem{HP: 400, ATK: 10, DEF: 6, AreaID: forward.StartArea}, &HeroItem{AreaID: forward.EndArea})
+	if result.HP != 400-getDamage(10, 6, 210) {
+		t.Errorf("HP = %d, 期望打过(1,2)的怪物后经通道到达终点", result.HP)
+	}
+	backward := convertForTest(t, m, [2]int{1, 5}, [2]int{1, 1})
+	result = findOptimalPath(backward, &HeroItem{HP: 400, ATK: 10, DEF: 6, AreaID: backward.StartArea}, &HeroItem{AreaID: backward.EndArea})
+	if result.HP != -1 {
+		t.Errorf("逆着箭头不应到达终点, HP = %d", result.HP)
+	}
+}
+
+// 无法确定终点的通道返回错误
+func TestUnresolvedPassage(t *testing.T) {
+	cases := []struct {
+		name    string
+		m       [][]int
+		portals map[[2]int][2]int
+	}{
+		{"箭头指向墙", [][]int{{1, 1, 1, 1}, {1, 0, 164, 1}, {1, 1, 1, 1}}, nil},
+		{"箭头指向地图外", [][]int{{0, 164}}, nil},
+		{"箭头形成环路", [][]int{{1, 1, 1, 1}, {0, 164, 163, 1}, {1, 1, 1, 1}}, nil},
+		{"传送门没有目标", [][]int{{1, 1, 1, 1}, {1, 0, PortalID, 1}, {1, 1, 1, 1}}, nil},
+	}
+	for _, c := range cases {
+		converter := NewMapToGraphConverter(c.m, treasureMap, monsterMap, npcMap, [2]int{-1, -1}, [2]int{-1, -1})
+		converter.SetPortals(c.portals)
+		if _, err := converter.Convert(); err == nil {
+			t.Errorf("%s: 期望转换失败", c.name)
+		}
+	}
+}
+
+// 传送门的目标是怪物时，落在怪物前
+func TestPortalOntoGate(t *testing.T) {
+	m := [][]int{
+		{1, 1, 1, 1, 1, 1},
+		{1, 0, PortalID, 1, 210, 0},
+		{1, 1, 1, 1, 1, 1},
+	}
+	converter := NewMapToGraphConverter(m, treasureMap, monsterMap, npcMap, [2]int{1, 1}, [2]int{1, 5})
+	converter.SetPortals(map[[2]int][2]int{{1, 2}: {1, 4}})
+	graph, err := converter.Convert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.Links) != 1 || graph.Areas[graph.Links[0].To].Beside == nil {
+		t.Fatalf("传送门应连接到(1,4)怪物前的落脚区域: %v", graph.Links)
+	}
+	gate := graph.GateAt(0, [2]int{1, 4})
+	if gate == nil || len(gate.Areas) != 2 {
+		t.Errorf("连接点(1,4)应连接落脚区域和终点区域: %+v", gate)
+	}
+}
+
+// 增量修改后通道终点变成怪物，结果与重新转换相同
+func TestPatchPassageOntoGate(t *testing.T) {
+	initDamageCache()
+	m := [][]int{
+		{1, 1, 1, 1, 1, 1, 1},
+		{1, 0, 164, 164, 0, 0, 1},
+		{1, 1, 1, 1, 1, 1, 1},
+	}
+	base := convertForTest(t, m, [2]int{1, 1}, [2]int{1, 5})
+	patched, err := base.WithCellChanged([2]int{1, 4}, 210)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := [][]int{m[0], {1, 0, 164, 164, 210, 0, 1}, m[2]}
+	full := convertForTest(t, changed, [2]int{1, 1}, [2]int{1, 5})
+	for _, graph := range []*Graph{patched, full} {
+		result := findOptimalPath(graph, &HeroItem{HP: 400, ATK: 10, DEF: 6, AreaID: graph.StartArea}, &HeroItem{AreaID: graph.EndArea})
+		if result.HP != 400-getDamage(10, 6, 210) {
+			t.Errorf("HP = %d, 期望打过(1,4)的怪物后到达终点", result.HP)
+		}
+	}
+}
+
+// NPC交易的前置位置不是连接点时，转换和加载图都返回错误，而不是让交易变成无条件的
+func TestNPCRequirementMustBeGate(t *testing.T) {
+	m := [][]int{
+		{1, 1, 1, 1, 1, 1, 1},
+		{1, 0, 94, 0, 210, 0, 1},
+		{1, 1, 1, 1, 1, 1, 1},
+	}
+	npcs := func(required [2]int) map[int]*NPC {
+		return map[int]*NPC{94: {Gain: NPCTrade{ATK: 1}, RequiredDefeated: [][2]int{required}}}
+	}
+	graph, err := NewMapToGraphConverter(m, treasureMap, monsterMap, npcs([2]int{1, 4}), [2]int{1, 1}, [2]int{1, 5}).Convert()
+	if err != nil {
+		t.Fatalf("前置位置是怪物: %v", err)
+	}
+	if _, err := NewMapToGraphConverter(m, treasureMap, monsterMap, npcs([2]int{1, 5}), [2]int{1, 1}, [2]int{1, 5}).Convert(); err == nil {
+		t.Error("前置位置是空地: 期望转换失败")
+	}
+
+	data, err := graph.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadGraphJSON(data, treasureMap, monsterMap, npcs([2]int{1, 4})); err != nil {
+		t.Errorf("加载前置位置是怪物的图: %v", err)
+	}
+	if _, err := LoadGraphJSON(data, treasureMap, monsterMap, npcs([2]int{0, 4})); err == nil {
+		t.Error("加载时前置位置是墙: 期望返回错误")
+	}
+}
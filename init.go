@@ -1,5 +1,7 @@
 package main
 
+import "time"
+
 // 全局变量
 var (
 	gameMap = [][]int{
@@ -37,6 +39,19 @@ var (
 		31: {Type: treasureHP, Value: 50},
 		21: {Type: treasureYellowKey, Value: 1},
 		22: {Type: treasureBlueKey, Value: 1},
+		32: {Type: treasureHP, Value: 100, Optional: true}, // 大血瓶：可留到需要时再拾取
+		33: {Type: treasureHP, Value: -50, Optional: true}, // 诅咒物品：拾取会扣血
+		41: {Type: treasureEquipment, Equip: &Equipment{Name: "铁剑", Slot: slotWeapon, ATK: 2}},
+		42: {Type: treasureEquipment, Equip: &Equipment{Name: "银剑", Slot: slotWeapon, ATK: 4}},
+		43: {Type: treasureEquipment, Equip: &Equipment{Name: "骑士剑", Slot: slotWeapon, ATK: 6}},
+		44: {Type: treasureEquipment, Equip: &Equipment{Name: "铁盾", Slot: slotShield, DEF: 2}},
+		45: {Type: treasureEquipment, Equip: &Equipment{Name: "银盾", Slot: slotShield, DEF: 4}},
+		46: {Type: treasureEquipment, Equip: &Equipment{Name: "骑士盾", Slot: slotShield, DEF: 6}},
+		51: {Type: treasureCurePoison, Optional: true},    // 解毒药水：留到中毒时再拾取
+		52: {Type: treasureCureWeak, Optional: true},      // 解衰药水：留到衰弱时再拾取
+		53: {Type: treasureMP, Value: 10},                 // 魔法药水
+		54: {Type: treasureCureCurse, Optional: true},     // 解咒药水：留到诅咒时再拾取
+		55: {Type: treasureMP, Value: 10, Optional: true}, // 可选的魔法药水：可留到需要时再拾取
 	}
 
 	monsterMap = map[int]*Monster{
@@ -52,8 +67,18 @@ var (
 		212: {HP: 45, ATK: 25, DEF: 4, Money: 3},
 		213: {HP: 39, ATK: 22, DEF: 2, Money: 2},
 		214: {HP: 166, ATK: 17, DEF: 12, Money: 0},
-		81:  {HP: 1}, // 黄门视作怪物
-		82:  {HP: 1}, // 蓝门视作怪物
+		215: {HP: 40, ATK: 26, DEF: 3, Money: 4, Special: ailmentPoison}, // 毒蝙蝠
+		216: {HP: 55, ATK: 30, DEF: 6, Money: 6, Special: ailmentWeak},   // 衰弱巫师
+		217: {HP: 70, ATK: 35, DEF: 8, Money: 8, Special: ailmentCurse},  // 诅咒骷髅
+		81:  {HP: 1},                                                     // 黄门视作怪物
+		82:  {HP: 1},                                                     // 蓝门视作怪物
+	}
+
+	// NPC：占据格子，交易一次后可通行
+	npcMap = map[int]*NPC{
+		91: {Cost: NPCTrade{Money: 10}, Gain: NPCTrade{YellowKeys: 1}}, // 商人：10金币买1把黄钥匙
+		92: {Cost: NPCTrade{YellowKeys: 1}, Gain: NPCTrade{Money: 10}}, // 商人：1把黄钥匙换10金币
+		93: {Gain: NPCTrade{ATK: 1, DEF: 1}},                           // 老人：一次性攻防+1
 	}
 
 	start      = [2]int{24, 6}
@@ -61,13 +86,65 @@ var (
 	initialAtk = int8(10)   // 初始攻击力
 	initialDef = int8(6)    // 初始防御力
 	initialHP  = int16(230) // 初始生命值
-	initialYK  = int8(1)    // 初始黄钥匙
-	initialBK  = int8(1)    // 初始蓝钥匙
+	initialYK  = int16(1)   // 初始黄钥匙
+	initialBK  = int16(1)   // 初始蓝钥匙
+
+	requiredYellowKeys = int16(0)
+	requiredBlueKeys   = int16(0)
+
+	simplifyGraph = true  // 搜索前化简图（删除无用怪物、合并走廊）
+	exportGraph   = false // 导出转换后的图到graph.json和graph.dot
+	graphFile     = ""    // 不为空时从该JSON文件（exportGraph导出的graph.json）加载图，跳过地图转换；也可以用-graph参数指定
+	analyzeGraph  = true  // 搜索前打印关卡结构分析（必经连接点、拿不到的宝物等）
+
+	// 剪枝规则（按本关调整）；pruneConfigFile不为空时改为从该JSON文件加载
+	pruneConfig = &PruneConfig{
+		Rules: []*PruneRule{
+			{Kind: pruneFights, Fights: 4, Value: 0},
+			{Kind: pruneFights, Fights: 6, Value: 1},
+			{Kind: pruneFights, Fights: 7, Value: 2},
+			{Kind: pruneFights, Fights: 11, Value: 4},
+			{Kind: pruneFights, Fights: 16, Value: 7},
+			{Kind: pruneFights, Fights: 21, Value: 9},
+			{Kind: pruneFights, Fights: 27, Value: 12},
+			{Kind: pruneMoney, Value: 45},
+			{Kind: pruneConsecutive, Fights: 5, Value: 2},
+			{Kind: pruneGoal},
+		},
+	}
+	pruneConfigFile = ""
+	searchTimeLimit = time.Duration(0) // 每次搜索的时间上限，0表示不限制
+	searchTopK      = 1                // 输出结果最好的几条不同路线
 
-	requiredATK        = int8(18) // 需要的攻击力
-	requiredDEF        = int8(13) // 需要的防御力
-	requiredYellowKeys = int8(0)
-	requiredBlueKeys   = int8(0)
+	// 比较结果的目标：按顺序逐项比较，Weighted为true时比较加权和，权重为负表示越小越好
+	// 例如{{atkdef, 1}, {hp, 1}}先比攻防和，{{hp, 1}, {doors, -1}}血量相同时少开门
+	searchObjective = &Objective{Terms: []ObjectiveTerm{{Metric: metricHP, Weight: 1}, {Metric: metricMoney, Weight: 1}}}
+	paretoMetrics   = []ObjectiveTerm(nil) // 非空时输出终点结果在这些指标上的帕累托前沿，例如{{hp, 1}, {keys, 1}}
+
+	// 终点条件，nil表示到达end且满足required*的要求
+	// 例如AllGoals(DefeatGoal([2]int{3, 4}), StatGoal(metricYellowKeys, 2))，或AnyGoal(ReachGoal(出口1), ReachGoal(出口2))
+	searchGoal = (*Goal)(nil)
+
+	// 终点后的Boss在monsterMap中的编号，非0时目标变为到达终点后能打赢Boss且打完剩下的血量最多，
+	// 不再使用钥匙要求，连续战斗剪枝的攻防要求由Boss推算（见bossTarget）
+	bossMonsterID = 214
+
+	// 勇士技能
+	skillList = []*Skill{
+		{Name: "二倍斩", MPCost: 10, ATKMultiplier: 2},
+		{Name: "护盾", MPCost: 5, DEFBonus: 5},
+	}
+
+	// 状态异常相关
+	// 区域图不记录步数，中毒按行动扣血：每走到一个连接点或经过一条单向连接算一次
+	poisonDamagePerAction = int16(10)        // 中毒时每次行动损失的生命
+	weakenATK             = int8(2)          // 衰弱时攻击力下降
+	weakenDEF             = int8(2)          // 衰弱时防御力下降
+	cureShopPrices        = map[uint8]int32{ // 商店解除状态异常的价格
+		ailmentPoison: 20,
+		ailmentWeak:   30,
+	}
+	cureShopPositions = [][3]int(nil) // 可以解除状态异常的商店位置（楼层, x, y），能到达其中之一时才能解除
 
 	damageCache = make(map[int8]map[int8]map[int]int16)
 )
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"os"
+	"os/signal"
 	"runtime"
 	"sync"
 	"time"
@@ -10,61 +14,130 @@ import (
 type HeroItem struct {
 	AreaID     int
 	HP         int16
+	MP         int16
 	ATK        int8
 	DEF        int8
 	MDEF       uint8
-	Money      uint8
-	YellowKeys int8
-	BlueKeys   int8
+	Money      int32
+	YellowKeys int16
+	BlueKeys   int16
+	Equipped   [equipSlotCount]*Equipment // 初始装备
 }
 
 type SearchResult struct {
 	HP             int16
-	Money          uint8
+	MP             int16
+	Money          int32
 	ATK            int8
 	DEF            int8
 	MDEF           uint8
-	YellowKeys     int8
-	BlueKeys       int8    // 新增蓝钥匙
-	Path           []int16 // 存储每次战斗损失的血量
+	YellowKeys     int16
+	BlueKeys       int16    // 新增蓝钥匙
+	Path           []Action // 依次执行的动作
 	DefeatedCount  int
 	CollectedCount int
+	Equipped       [equipSlotCount]*Equipment // 最终穿戴的装备（ATK/DEF已计入加成）
+	Ailments       uint8                      // 最终的状态异常
+	Optimal        bool                       // 结果保证最优（没有启发式剪枝生效，搜索也没有提前停止）
+	Interrupted    bool                       // 因取消或预算用尽提前停止，结果是已找到的最优解
+	Routes         []SearchResult             // TopK>1时结果最好的K条不同路线（第一条即本结果），按结果从好到差排列
+	Pareto         []SearchResult             // 终点结果在指定指标上的帕累托前沿，按结果从好到差排列
+	Score          []int                      // 在搜索目标下的得分，按字典序比较
 }
 
 // 输出路径函数（回溯 reconstruct）
-func printPath(path []int16) {
+func printPath(path []Action) {
 	fmt.Printf("\n路径步骤:\n")
 	if len(path) == 0 {
 		fmt.Println("无战斗记录")
 		return
 	}
-	for i := 0; i < len(path); i += 2 {
-		damage := path[i]
-		pos := path[i+1]
-		if damage == -1 && pos == -1 {
-			fmt.Printf("%d. 购买攻击力+1 (花费40金币)\n", i/2+1)
-		} else if damage == -2 && pos == -2 {
-			fmt.Printf("%d. 购买防御力+1 (花费40金币)\n", i/2+1)
-		} else {
-			fmt.Printf("%d. 战斗损失%d血, 战斗at %d, %d\n", i/2+1, damage, pos>>8, pos%(1<<8))
+	for i, action := range path {
+		pos := formatActionPos(action)
+		switch action.Type {
+		case actionBuyATK:
+			fmt.Printf("%d. 购买攻击力+1 (花费40金币)\n", i+1)
+		case actionBuyDEF:
+			fmt.Printf("%d. 购买防御力+1 (花费40金币)\n", i+1)
+		case actionNPC:
+			fmt.Printf("%d. 与NPC交易 at %s\n", i+1, pos)
+		case actionPickItem:
+			fmt.Printf("%d. 拾取物品 at %s\n", i+1, pos)
+		case actionCure:
+			fmt.Printf("%d. 在商店解除%s (花费%d金币)\n", i+1, ailmentName(action.Ailment), cureShopPrices[action.Ailment])
+		case actionTrigger:
+			fmt.Printf("%d. 踩上机关 at %s\n", i+1, pos)
+		case actionMove:
+			fmt.Printf("%d. 经单向通道进入区域%d\n", i+1, action.Area)
+		default:
+			if action.Skill >= 0 {
+				fmt.Printf("%d. 使用%s战斗损失%d血, 战斗at %s\n", i+1, skillList[action.Skill].Name, action.Damage, pos)
+			} else {
+				fmt.Printf("%d. 战斗损失%d血, 战斗at %s\n", i+1, action.Damage, pos)
+			}
 		}
 	}
 }
 
+// 格式化动作位置，多层塔中额外标注楼层
+func formatActionPos(action Action) string {
+	if action.Floor == 0 {
+		return fmt.Sprintf("%d, %d", action.Pos[0], action.Pos[1])
+	}
+	return fmt.Sprintf("%d, %d (%dF)", action.Pos[0], action.Pos[1], action.Floor)
+}
+
+// 状态异常名称
+func ailmentName(ailment uint8) string {
+	switch ailment {
+	case ailmentPoison:
+		return "中毒"
+	case ailmentWeak:
+		return "衰弱"
+	case ailmentCurse:
+		return "诅咒"
+	}
+	return "未知状态"
+}
+
 // reconstructPath: 回溯生成完整路径
-func reconstructPath(dp map[int64]*State, endKey int64) []int16 {
-	path := []int16{}
-	for key := endKey; key != 0; {
-		state := dp[key]
-		if state == nil || (state.PrevKey == 0 && (state.Action[0] == 0 && state.Action[1] == 0)) {
-			break
+func reconstructPath(endState *State) []Action {
+	path := []Action{}
+	for state := endState; state != nil && state.Action.Type != actionNone; state = state.Prev {
+		if len(state.Action.Parts) > 0 {
+			// 宏连接点展开为单独的战斗
+			path = append(append([]Action{}, state.Action.Parts...), path...)
+		} else {
+			path = append([]Action{state.Action}, path...)
 		}
-		path = append([]int16{state.Action[0], state.Action[1]}, path...)
-		key = state.PrevKey
 	}
 	return path
 }
 
+// 把图导出到graph.json和graph.dot
+func exportGraphFiles(graph *Graph) {
+	data, err := graph.ToJSON()
+	if err != nil {
+		fmt.Printf("导出JSON失败: %v\n", err)
+		return
+	}
+	if err := os.WriteFile("graph.json", data, 0644); err != nil {
+		fmt.Printf("写入graph.json失败: %v\n", err)
+	}
+	if err := os.WriteFile("graph.dot", []byte(graph.ToDOT()), 0644); err != nil {
+		fmt.Printf("写入graph.dot失败: %v\n", err)
+	}
+}
+
+// 从ToJSON导出的文件加载图
+func loadGraphFile(path string) (*Graph, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return LoadGraphJSON(raw, treasureMap, monsterMap, npcMap)
+}
+
 func printStats() {
 	var memStats runtime.MemStats
 	runtime.ReadMemStats(&memStats)
@@ -108,11 +181,13 @@ type result struct {
 }
 
 func main() {
+	flag.StringVar(&graphFile, "graph", graphFile, "从JSON文件加载图，跳过地图转换")
+	flag.Parse()
 	startTime := time.Now()
 	initDamageCache()
 
 	// 创建转换器并运行测试
-	converter := NewMapToGraphConverter(gameMap, treasureMap, monsterMap, start, end)
+	converter := NewMapToGraphConverter(gameMap, treasureMap, monsterMap, npcMap, start, end)
 	length := len(gameMap[0])
 	rows := len(gameMap)
 	for i, row := range gameMap {
@@ -130,9 +205,82 @@ func main() {
 			fmt.Println()
 		}
 	}
-	graph := converter.Convert()
+	if pruneConfigFile != "" {
+		config, err := LoadPruneConfig(pruneConfigFile)
+		if err != nil {
+			fmt.Printf("加载剪枝配置失败: %v\n", err)
+			return
+		}
+		pruneConfig = config
+	}
+	if bossMonsterID != 0 {
+		goal := searchGoal
+		if goal == nil {
+			goal = ReachGoal(end)
+		}
+		searchGoal = AllGoals(goal, BossGoal(bossMonsterID))
+		searchObjective = searchObjective.withBoss(bossMonsterID)
+		requiredYellowKeys, requiredBlueKeys = 0, 0
+		if pruneConfig.enabled(pruneConsecutive) {
+			atk, def := bossTarget(bossMonsterID, initialHP)
+			fmt.Printf("连续战斗剪枝的目标: 攻击%d, 防御%d（不使用技能打赢Boss%d）\n", atk, def, bossMonsterID)
+		}
+	} else if pruneConfig.enabled(pruneConsecutive) {
+		fmt.Println("没有设置Boss，连续战斗剪枝没有攻防目标，不会生效")
+	}
+	if err := searchObjective.Validate(); err != nil {
+		fmt.Printf("搜索目标无效: %v\n", err)
+		return
+	}
+	if err := validateTerms(paretoMetrics); err != nil {
+		fmt.Printf("帕累托指标无效: %v\n", err)
+		return
+	}
+	var graph *Graph
+	if graphFile != "" {
+		loaded, err := loadGraphFile(graphFile)
+		if err != nil {
+			fmt.Printf("加载图失败: %v\n", err)
+			return
+		}
+		// 加载的图没有原始地图，不能拆墙，只搜索这张图
+		graph = loaded
+		graph.BreakPoints = []*BreakPoint{}
+	} else {
+		converted, err := converter.Convert()
+		if err != nil {
+			fmt.Printf("地图转换失败: %v\n", err)
+			return
+		}
+		graph = converted
+	}
+	if searchGoal != nil {
+		if err := graph.ValidateGoal(searchGoal); err != nil {
+			fmt.Printf("终点条件无效: %v\n", err)
+			return
+		}
+	}
+	for _, pos := range cureShopPositions {
+		if err := graph.ValidateGoal(&Goal{Kind: goalReach, Floor: pos[0], Pos: [2]int{pos[1], pos[2]}}); err != nil {
+			fmt.Printf("商店位置无效: %v\n", err)
+			return
+		}
+	}
+	// 化简图时需要保留的位置：终点条件和商店
+	keep := append(searchGoal.positions(), cureShopPositions...)
 	for _, point := range graph.BreakPoints {
-		fmt.Printf("BreakPoint at %v, AreaIDs: %v\n", point.Pos, point.AreaIDs)
+		fmt.Printf("BreakPoint at %v, AreaIDs: %v, Gates: %v, Score: %d, 同效果位置: %v\n",
+			point.Pos, point.AreaIDs, point.Gates, point.Score, point.Alternatives)
+	}
+	if analyzeGraph {
+		graph.Analyze().Print()
+	}
+	// 化简一次，报告和没有破墙点时的搜索共用化简后的图
+	searchGraph := graph
+	if simplifyGraph {
+		simplified, report := graph.Simplify(keep...)
+		report.Print()
+		searchGraph = simplified
 	}
 
 	// 并发处理逻辑
@@ -143,6 +291,44 @@ func main() {
 		mu        sync.Mutex // 用于保护共享变量
 	)
 
+	// 按Ctrl+C停止搜索，输出已找到的最优解
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// 在给定的图上搜索，workers为单次搜索使用的协程数
+	search := func(g *Graph, workers int, onImprove func(SearchResult)) SearchResult {
+		return findOptimalPathWithOptions(g, &HeroItem{
+			HP:         initialHP,
+			ATK:        initialAtk,
+			DEF:        initialDef,
+			AreaID:     g.StartArea,
+			YellowKeys: initialYK,
+			BlueKeys:   initialBK,
+		}, &HeroItem{
+			AreaID:     g.EndArea,
+			YellowKeys: requiredYellowKeys,
+			BlueKeys:   requiredBlueKeys,
+		}, &SearchOptions{
+			Prune:     pruneConfig,
+			Workers:   workers,
+			Context:   ctx,
+			TimeLimit: searchTimeLimit,
+			TopK:      searchTopK,
+			Objective: searchObjective,
+			Pareto:    paretoMetrics,
+			Goal:      searchGoal,
+			OnImprove: onImprove,
+		})
+	}
+
+	// 没有破墙点时只搜索原图，由多个协程并行完成这一次搜索
+	if len(graph.BreakPoints) == 0 {
+		maxResult = search(searchGraph, runtime.GOMAXPROCS(0), func(r SearchResult) {
+			fmt.Printf("找到更优解: HP=%d, Money=%d\n", r.HP, r.Money)
+		})
+		maxHP = maxResult.HP
+	}
+
 	poolSize := 6
 	taskCh := make(chan task, len(graph.BreakPoints))
 	resultCh := make(chan result, len(graph.BreakPoints))
@@ -156,33 +342,16 @@ func main() {
 			for t := range taskCh {
 				x, y := t.point[0], t.point[1]
 				fmt.Printf("Task at point %v, x: %v, y: %v\n", t.point[0], x, y)
-				// 深拷贝 gameMap
-				newGameMap := make([][]int, len(gameMap))
-				for i := range gameMap {
-					newGameMap[i] = make([]int, len(gameMap[i]))
-					copy(newGameMap[i], gameMap[i])
+				// 在原图上增量拆墙，未受影响的区域ID保持不变
+				newGraph, err := graph.WithWallRemoved(t.point)
+				if err != nil {
+					fmt.Printf("point: %v, 拆墙失败: %v\n", t.point, err)
+					continue
+				}
+				if simplifyGraph {
+					newGraph, _ = newGraph.Simplify(keep...)
 				}
-				newGameMap[x][y] = 0 // 修改副本
-
-				// 执行原逻辑
-				newConvertor := NewMapToGraphConverter(
-					newGameMap, treasureMap, monsterMap, start, end,
-				)
-				newGraph := newConvertor.Convert()
-				res := findOptimalPath(newGraph, &HeroItem{
-					HP:         initialHP,
-					ATK:        initialAtk,
-					DEF:        initialDef,
-					AreaID:     graph.StartArea,
-					YellowKeys: initialYK,
-					BlueKeys:   initialBK,
-				}, &HeroItem{
-					ATK:        requiredATK,
-					DEF:        requiredDEF,
-					AreaID:     graph.EndArea,
-					YellowKeys: requiredYellowKeys,
-					BlueKeys:   requiredBlueKeys,
-				})
+				res := search(newGraph, 1, nil)
 
 				// 发送结果
 				fmt.Printf("point: %v, hp: %v\n", t.point, res.HP)
@@ -209,7 +378,7 @@ func main() {
 		defer close(done)
 		for r := range resultCh {
 			mu.Lock()
-			if r.hp > maxHP {
+			if r.hp > 0 && (maxHP <= 0 || compareScores(r.heroResult.Score, maxResult.Score) > 0) {
 				maxHP = r.hp
 				maxResult = r.heroResult
 				bestPoint = r.point
@@ -225,13 +394,49 @@ func main() {
 	// 等待结果收集完成
 	<-done
 
-	//graph.Print()
+	if exportGraph {
+		exportGraphFiles(graph)
+	}
 
 	if maxHP > 0 {
 		fmt.Printf("\n=== 找到最优解 ===\n")
 		fmt.Printf("最终属性: HP=%d, Money=%d", maxResult.HP, maxResult.Money)
-		fmt.Printf("破点：%v", bestPoint)
+		if len(graph.BreakPoints) > 0 {
+			fmt.Printf("破点：%v", bestPoint)
+		}
+		if maxResult.Optimal {
+			fmt.Printf(" (保证最优)")
+		} else if maxResult.Interrupted {
+			fmt.Printf(" (搜索提前停止)")
+		}
+		for _, item := range maxResult.Equipped {
+			if item != nil {
+				fmt.Printf(" 装备：%s", item.Name)
+			}
+		}
+		if bossMonsterID != 0 {
+			if damage, beatable := bossDamage(maxResult.ATK, maxResult.DEF, maxResult.MP, bossMonsterID); beatable {
+				fmt.Printf(" 打Boss损失%d血, 剩余%d血", damage, maxResult.HP-damage)
+			} else {
+				fmt.Printf(" 打不过Boss")
+			}
+		}
 		printPath(maxResult.Path)
+		for i, route := range maxResult.Routes {
+			if i == 0 {
+				continue
+			}
+			fmt.Printf("\n=== 第%d好的路线 ===\n", i+1)
+			fmt.Printf("最终属性: HP=%d, Money=%d, ATK=%d, DEF=%d, 击败%d个", route.HP, route.Money, route.ATK, route.DEF, route.DefeatedCount)
+			printPath(route.Path)
+		}
+		if len(maxResult.Pareto) > 0 {
+			fmt.Printf("\n=== 帕累托前沿 ===\n")
+			for _, point := range maxResult.Pareto {
+				fmt.Printf("HP=%d, ATK=%d, DEF=%d, Money=%d, 黄钥匙=%d, 蓝钥匙=%d, 击败%d个\n",
+					point.HP, point.ATK, point.DEF, point.Money, point.YellowKeys, point.BlueKeys, point.DefeatedCount)
+			}
+		}
 	} else {
 		fmt.Printf("\n=== 找不到最优解 ===\n")
 	}
@@ -1,24 +1,41 @@
 package main
 
+import "fmt"
+
 type Monster struct {
-	HP    int16 // 2 bytes
-	ATK   int8  // 1 byte
-	DEF   int8  // 1 byte
-	ID    int
-	Money uint8
+	HP      int16 // 2 bytes
+	ATK     int8  // 1 byte
+	DEF     int8  // 1 byte
+	ID      int
+	Money   uint8
+	Special uint8 // 特殊能力：击败后附加的状态异常（ailmentPoison等）
+}
+
+// 连接点：把区域连接起来的格子（怪物、门、NPC、可选物品、动态格子）
+// ID为在Graph.Gates中的下标，搜索中也用作击败状态的位索引
+type Gate struct {
+	ID        int
+	MonsterID int // 格子上的元素ID
+	Monster   *Monster
+	NPC       *NPC         // 非nil表示该连接点是NPC而不是怪物
+	Item      *Treasure    // 非nil表示该连接点是可选拾取的物品
+	Dynamic   *DynamicCell // 非nil表示该连接点是由触发器控制的格子
+	Chain     []*Gate      // 非空表示该连接点是走廊中合并的怪物链（按Areas[0]一侧进入的顺序）
+	Floor     int
+	Pos       [2]int
+	Areas     []int // 相邻的区域ID
 }
 
-type GlobalMonster struct {
-	Key            string
-	ID             int
-	Monster        *Monster
-	Pos            [2]int
-	ConnectedAreas []int
+// 是否为需要战斗的怪物（门、NPC、物品不算）
+func (gate *Gate) isMonster() bool {
+	return gate.NPC == nil && gate.Item == nil && gate.Dynamic == nil && len(gate.Chain) == 0 &&
+		gate.MonsterID != YellowDoorID && gate.MonsterID != BlueDoorID
 }
 
-type MonsterConnection struct {
-	MonsterID      int
-	Monster        *Monster
-	MonsterPos     [2]int
-	ConnectedAreas []int
+// 连接点的名称（多层塔中带楼层前缀）
+func (gate *Gate) Name() string {
+	if gate.Floor == 0 {
+		return fmt.Sprintf("%d,%d", gate.Pos[0], gate.Pos[1])
+	}
+	return fmt.Sprintf("%d:%d,%d", gate.Floor, gate.Pos[0], gate.Pos[1])
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// NPC交易中涉及的属性变化（代价或收益）
+type NPCTrade struct {
+	HP         int16
+	ATK        int8
+	DEF        int8
+	MDEF       uint8
+	Money      int32
+	YellowKeys int16
+	BlueKeys   int16
+}
+
+// NPC：占据一个格子，只能交易一次，交易后该格子变为可通行
+type NPC struct {
+	Cost             NPCTrade // 交易代价
+	Gain             NPCTrade // 交易收益
+	RequiredDefeated [][2]int // 交易前必须击败的怪物位置（可选）
+}
+
+// 检查NPC交易的前置条件：需要先击败的位置必须是连接点，否则交易会变成无条件的
+func checkNPCRequirements(gates []*Gate, isGate func(floor int, pos [2]int) bool) error {
+	for _, gate := range gates {
+		if gate.NPC == nil {
+			continue
+		}
+		for _, pos := range gate.NPC.RequiredDefeated {
+			if !isGate(gate.Floor, pos) {
+				return fmt.Errorf("NPC%v的前置位置%v不是能到达的连接点", gate.Pos, pos)
+			}
+		}
+	}
+	return nil
+}
+
+// 检查当前属性是否负担得起交易代价（扣血后必须存活，其余属性不能扣成负数）
+// 并且获得收益后各属性不超出字段的范围
+func (n *NPC) canAfford(hp int16, mdef uint8, atk, def int8, money int32, yellowKeys, blueKeys int16) bool {
+	if hp <= n.Cost.HP || mdef < n.Cost.MDEF || atk < n.Cost.ATK || def < n.Cost.DEF ||
+		money < n.Cost.Money || yellowKeys < n.Cost.YellowKeys || blueKeys < n.Cost.BlueKeys {
+		return false
+	}
+	return int(hp)-int(n.Cost.HP)+int(n.Gain.HP) <= math.MaxInt16 &&
+		int(mdef)-int(n.Cost.MDEF)+int(n.Gain.MDEF) <= math.MaxUint8 &&
+		int(atk)-int(n.Cost.ATK)+int(n.Gain.ATK) <= math.MaxInt8 &&
+		int(def)-int(n.Cost.DEF)+int(n.Gain.DEF) <= math.MaxInt8 &&
+		int(money)-int(n.Cost.Money)+int(n.Gain.Money) <= math.MaxInt32 &&
+		int(yellowKeys)-int(n.Cost.YellowKeys)+int(n.Gain.YellowKeys) <= math.MaxInt16 &&
+		int(blueKeys)-int(n.Cost.BlueKeys)+int(n.Gain.BlueKeys) <= math.MaxInt16
+}
+
+// 应用交易：先扣除代价，再获得收益（调用前需用canAfford检查）
+func (n *NPC) trade(hp int16, mdef uint8, atk, def int8, money int32, yellowKeys, blueKeys int16) (int16, uint8, int8, int8, int32, int16, int16) {
+	hp = hp - n.Cost.HP + n.Gain.HP
+	mdef = mdef - n.Cost.MDEF + n.Gain.MDEF
+	atk = atk - n.Cost.ATK + n.Gain.ATK
+	def = def - n.Cost.DEF + n.Gain.DEF
+	money = money - n.Cost.Money + n.Gain.Money
+	yellowKeys = yellowKeys - n.Cost.YellowKeys + n.Gain.YellowKeys
+	blueKeys = blueKeys - n.Cost.BlueKeys + n.Gain.BlueKeys
+	return hp, mdef, atk, def, money, yellowKeys, blueKeys
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// 结果指标：到达终点时勇士的属性
+const (
+	metricHP         = "hp"
+	metricMP         = "mp"
+	metricATK        = "atk"    // 计入装备加成
+	metricDEF        = "def"    // 计入装备加成
+	metricATKDEF     = "atkdef" // 攻防和
+	metricMDEF       = "mdef"
+	metricMoney      = "money"
+	metricYellowKeys = "yellow_keys"
+	metricBlueKeys   = "blue_keys"
+	metricKeys       = "keys"     // 剩下的黄蓝钥匙总数
+	metricDoors      = "doors"    // 打开的黄门和蓝门数（用掉的钥匙）
+	metricDefeated   = "defeated" // 击败的连接点数（走廊怪物链按其中的怪物数计）
+	metricBossHP     = "boss_hp"  // 以最终攻防与Boss战斗后剩下的血量（打不过时不大于0，无论血量多少都打不过时为math.MinInt16）
+)
+
+// 目标中的一项：Weight为正时越大越好，为负时越小越好
+type ObjectiveTerm struct {
+	Metric  string
+	Weight  int
+	Monster int // boss_hp：Boss在monsterMap中的编号
+}
+
+// 搜索目标：按Terms的顺序逐项比较（字典序），Weighted时只比较各项的加权和
+type Objective struct {
+	Terms    []ObjectiveTerm
+	Weighted bool
+}
+
+// 默认目标：血量最高，血量相同时金币最多
+var defaultObjective = &Objective{Terms: []ObjectiveTerm{{Metric: metricHP, Weight: 1}, {Metric: metricMoney, Weight: 1}}}
+
+// 检查目标中的指标和权重
+func (o *Objective) Validate() error {
+	if len(o.Terms) == 0 {
+		return fmt.Errorf("目标至少需要一项指标")
+	}
+	return validateTerms(o.Terms)
+}
+
+func validateTerms(terms []ObjectiveTerm) error {
+	for _, term := range terms {
+		if !validMetric(term.Metric) {
+			return fmt.Errorf("未知的指标: %s", term.Metric)
+		}
+		if _, exists := monsterMap[term.Monster]; term.Metric == metricBossHP && !exists {
+			return fmt.Errorf("指标%s的怪物%d不存在", term.Metric, term.Monster)
+		}
+		if term.Weight == 0 {
+			return fmt.Errorf("指标%s的权重不能为0", term.Metric)
+		}
+		// DP表中血量、魔法和攻防高的状态会淘汰低的，这些指标不能越小越好
+		if term.Weight < 0 && !keyedMetric(term.Metric) {
+			return fmt.Errorf("指标%s只能越大越好", term.Metric)
+		}
+	}
+	return nil
+}
+
+func validMetric(metric string) bool {
+	switch metric {
+	case metricHP, metricMP, metricATK, metricDEF, metricATKDEF, metricMDEF, metricMoney,
+		metricYellowKeys, metricBlueKeys, metricKeys, metricDoors, metricDefeated, metricBossHP:
+		return true
+	}
+	return false
+}
+
+// 指标是否由状态key决定（key不同的状态不会互相淘汰）
+func keyedMetric(metric string) bool {
+	switch metric {
+	case metricMoney, metricYellowKeys, metricBlueKeys, metricKeys, metricDoors, metricDefeated:
+		return true
+	}
+	return false
+}
+
+// 目标的首要指标是否不超过最终血量（血量或打Boss后的血量），此时可以用血量上限做分支定界
+func (o *Objective) hpFirst() bool {
+	if o.Weighted || len(o.Terms) == 0 || o.Terms[0].Weight <= 0 {
+		return false
+	}
+	return o.Terms[0].Metric == metricHP || o.Terms[0].Metric == metricBossHP
+}
+
+// 把目标中的血量换成打Boss后剩下的血量
+func (o *Objective) withBoss(monsterID int) *Objective {
+	boss := &Objective{Terms: append([]ObjectiveTerm{}, o.Terms...), Weighted: o.Weighted}
+	for i, term := range boss.Terms {
+		if term.Metric == metricHP {
+			boss.Terms[i] = ObjectiveTerm{Metric: metricBossHP, Weight: term.Weight, Monster: monsterID}
+		}
+	}
+	return boss
+}
+
+// 状态在目标下的得分，得分按字典序比较，越大越好
+func (o *Objective) score(state *State, gates []*Gate) []int {
+	if o.Weighted {
+		sum := 0
+		for _, term := range o.Terms {
+			sum += term.Weight * term.value(state, gates)
+		}
+		return []int{sum}
+	}
+	return weightedValues(o.Terms, state, gates)
+}
+
+// 各项指标的值乘以权重，使每一项都是越大越好
+func weightedValues(terms []ObjectiveTerm, state *State, gates []*Gate) []int {
+	values := make([]int, len(terms))
+	for i, term := range terms {
+		values[i] = term.Weight * term.value(state, gates)
+	}
+	return values
+}
+
+// 状态在该项指标上的值
+func (term ObjectiveTerm) value(state *State, gates []*Gate) int {
+	if term.Metric == metricBossHP {
+		damage, beatable := bossDamage(state.TotalATK(), state.TotalDEF(), state.MP, term.Monster)
+		if !beatable {
+			return math.MinInt16
+		}
+		return int(state.HP) - int(damage)
+	}
+	return metricValue(term.Metric, state, gates)
+}
+
+// 状态在某项指标上的值（boss_hp需要怪物编号，见ObjectiveTerm.value）
+func metricValue(metric string, state *State, gates []*Gate) int {
+	switch metric {
+	case metricHP:
+		return int(state.HP)
+	case metricMP:
+		return int(state.MP)
+	case metricATK:
+		return int(state.TotalATK())
+	case metricDEF:
+		return int(state.TotalDEF())
+	case metricATKDEF:
+		return int(state.TotalATK()) + int(state.TotalDEF())
+	case metricMDEF:
+		return int(state.MDEF)
+	case metricMoney:
+		return int(state.Money)
+	case metricYellowKeys:
+		return int(state.YellowKeys)
+	case metricBlueKeys:
+		return int(state.BlueKeys)
+	case metricKeys:
+		return int(state.YellowKeys) + int(state.BlueKeys)
+	case metricDoors:
+		doors := 0
+		for _, gate := range gates {
+			if (gate.MonsterID == YellowDoorID || gate.MonsterID == BlueDoorID) && state.DefeatedMonsters.IsSet(gate.ID) {
+				doors++
+			}
+		}
+		return doors
+	case metricDefeated:
+		return defeatedCount(state.DefeatedMonsters, gates)
+	}
+	return 0
+}
+
+// 按字典序比较两个得分：a更好时返回1，b更好时返回-1，相同时返回0
+func compareScores(a, b []int) int {
+	for i := range a {
+		if i >= len(b) || a[i] > b[i] {
+			return 1
+		}
+		if a[i] < b[i] {
+			return -1
+		}
+	}
+	if len(a) < len(b) {
+		return -1
+	}
+	return 0
+}
+
+// 终点结果在指定指标上的帕累托前沿：保留没有被其他结果在所有指标上超过的结果
+// 各项指标的值都相同时只保留目标下更好的一个
+type paretoFront struct {
+	terms     []ObjectiveTerm
+	objective *Objective
+	gates     []*Gate
+	states    []*State
+	points    [][]int // 与states一一对应的指标值（已乘以权重）
+}
+
+func newParetoFront(terms []ObjectiveTerm, objective *Objective, gates []*Gate) *paretoFront {
+	return &paretoFront{terms: terms, objective: objective, gates: gates}
+}
+
+// 加入一个到达终点的状态，返回它是否进入前沿
+func (p *paretoFront) offer(state *State) bool {
+	point := weightedValues(p.terms, state, p.gates)
+	for i, existing := range p.points {
+		if !pointCovers(existing, point) {
+			continue
+		}
+		// 指标相同时按目标取更好的
+		if !pointCovers(point, existing) ||
+			compareScores(p.objective.score(state, p.gates), p.objective.score(p.states[i], p.gates)) <= 0 {
+			return false
+		}
+	}
+
+	keptStates, keptPoints := p.states[:0], p.points[:0]
+	for i, existing := range p.points {
+		if !pointCovers(point, existing) {
+			keptStates = append(keptStates, p.states[i])
+			keptPoints = append(keptPoints, existing)
+		}
+	}
+	p.states = append(keptStates, state)
+	p.points = append(keptPoints, point)
+	return true
+}
+
+// 前沿中的状态，按目标从好到差排列
+func (p *paretoFront) sorted() []*State {
+	scores := make(map[*State][]int, len(p.states))
+	for _, state := range p.states {
+		scores[state] = p.objective.score(state, p.gates)
+	}
+	states := append([]*State{}, p.states...)
+	for i := 1; i < len(states); i++ {
+		for j := i; j > 0 && compareScores(scores[states[j]], scores[states[j-1]]) > 0; j-- {
+			states[j], states[j-1] = states[j-1], states[j]
+		}
+	}
+	return states
+}
+
+// 指标值a是否每一项都不比b差
+func pointCovers(a, b []int) bool {
+	for i := range a {
+		if a[i] < b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package main
+
+import "testing"
+
+func TestObjectiveValidate(t *testing.T) {
+	cases := []struct {
+		objective *Objective
+		valid     bool
+	}{
+		{defaultObjective, true},
+		{&Objective{Terms: []ObjectiveTerm{{Metric: metricKeys, Weight: -1}}}, true},
+		{&Objective{}, false},
+		{&Objective{Terms: []ObjectiveTerm{{Metric: "exp", Weight: 1}}}, false},
+		{&Objective{Terms: []ObjectiveTerm{{Metric: metricHP, Weight: 0}}}, false},
+		{&Objective{Terms: []ObjectiveTerm{{Metric: metricHP, Weight: -1}}}, false},
+		{&Objective{Terms: []ObjectiveTerm{{Metric: metricBossHP, Weight: 1, Monster: -1}}}, false},
+	}
+	for i, c := range cases {
+		if err := c.objective.Validate(); (err == nil) != c.valid {
+			t.Errorf("用例%d: Validate() = %v, 期望有效=%v", i, err, c.valid)
+		}
+	}
+}
+
+func TestObjectiveScore(t *testing.T) {
+	state := &State{HP: 100, Money: 7, YellowKeys: 2, BlueKeys: 1, DefeatedMonsters: NewExtendedBitSet(8)}
+	lexical := &Objective{Terms: []ObjectiveTerm{{Metric: metricHP, Weight: 1}, {Metric: metricKeys, Weight: -1}}}
+	if score := lexical.score(state, nil); len(score) != 2 || score[0] != 100 || score[1] != -3 {
+		t.Errorf("字典序得分 = %v, 期望[100 -3]", score)
+	}
+	weighted := &Objective{Terms: []ObjectiveTerm{{Metric: metricHP, Weight: 1}, {Metric: metricMoney, Weight: 10}}, Weighted: true}
+	if score := weighted.score(state, nil); len(score) != 1 || score[0] != 170 {
+		t.Errorf("加权得分 = %v, 期望[170]", score)
+	}
+}
+
+// 前沿只保留没有被其他结果在所有指标上超过的结果
+func TestParetoFront(t *testing.T) {
+	terms := []ObjectiveTerm{{Metric: metricHP, Weight: 1}, {Metric: metricKeys, Weight: 1}}
+	front := newParetoFront(terms, defaultObjective, nil)
+	point := func(hp int16, keys int16, money int32) *State {
+		return &State{HP: hp, YellowKeys: keys, Money: money, DefeatedMonsters: NewExtendedBitSet(8)}
+	}
+
+	if !front.offer(point(100, 1, 0)) || !front.offer(point(80, 3, 0)) {
+		t.Fatal("互不支配的结果都应进入前沿")
+	}
+	if front.offer(point(90, 1, 0)) {
+		t.Error("被(100,1)支配的结果不应进入前沿")
+	}
+	if front.offer(point(100, 1, 0)) {
+		t.Error("指标相同且目标不更好的结果不应进入前沿")
+	}
+	if !front.offer(point(100, 1, 5)) {
+		t.Error("指标相同但金币更多的结果应替换原来的")
+	}
+	if !front.offer(point(120, 3, 0)) {
+		t.Error("支配所有结果的结果应进入前沿")
+	}
+	if len(front.states) != 1 || front.states[0].HP != 120 {
+		t.Errorf("前沿 = %v, 期望只剩(120,3)", front.states)
+	}
+}
+
+func TestSearchParetoFront(t *testing.T) {
+	initDamageCache()
+	terms := []ObjectiveTerm{{Metric: metricHP, Weight: 1}, {Metric: metricMoney, Weight: 1}, {Metric: metricKeys, Weight: 1}}
+	for _, c := range sampleCases {
+		graph := convertForTest(t, c.m, c.start, c.end)
+		best := searchSample(t, graph, c, &SearchOptions{Prune: &PruneConfig{Exact: true}})
+		result := searchSample(t, graph, c, &SearchOptions{Prune: &PruneConfig{Exact: true}, Pareto: terms})
+		if len(result.Pareto) == 0 || compareScores(result.Pareto[0].Score, best.Score) != 0 {
+			t.Errorf("%s: 前沿中最好的结果应为最优解 %v", c.name, best.Score)
+			continue
+		}
+		values := func(r SearchResult) []int {
+			return []int{int(r.HP), int(r.Money), int(r.YellowKeys) + int(r.BlueKeys)}
+		}
+		for i, a := range result.Pareto {
+			for j, b := range result.Pareto {
+				if i != j && pointCovers(values(a), values(b)) {
+					t.Errorf("%s: 前沿中的结果%v支配了%v", c.name, values(a), values(b))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 拆掉一面墙后的新图
+func (g *Graph) WithWallRemoved(pos [2]int) (*Graph, error) {
+	return g.WithCellChanged(pos, 0)
+}
+
+// 修改一个格子后的新图：只重新划分该格子及四周受影响的区域和连接点，其余区域ID保持不变
+// 被合并掉的区域保留为空区域，拆分出的新区域追加在末尾。仅支持单层地图，
+// 多层塔图和从JSON加载的图没有原始地图，返回错误
+func (g *Graph) WithCellChanged(pos [2]int, tile int) (*Graph, error) {
+	src := g.source
+	if src == nil {
+		return nil, fmt.Errorf("只支持由MapToGraphConverter生成的单层地图")
+	}
+	if pos[0] < 0 || pos[0] >= src.rows || pos[1] < 0 || pos[1] >= src.cols {
+		return nil, fmt.Errorf("位置%v不在地图内", pos)
+	}
+
+	gameMap := make([][]int, len(src.gameMap))
+	for i := range src.gameMap {
+		gameMap[i] = make([]int, len(src.gameMap[i]))
+		copy(gameMap[i], src.gameMap[i])
+	}
+	gameMap[pos[0]][pos[1]] = tile
+
+	c := NewMapToGraphConverter(gameMap, src.treasureMap, src.monsterMap, src.npcMap, src.start, src.end)
+	c.SetPortals(src.portals)
+	c.SetTriggers(src.triggers)
+
+	areaMap := make([][]int, len(g.AreaMap))
+	for i := range g.AreaMap {
+		areaMap[i] = make([]int, len(g.AreaMap[i]))
+		copy(areaMap[i], g.AreaMap[i])
+	}
+
+	// 受影响的区域：格子本身和四周格子所属的区域
+	cells := [][2]int{pos}
+	for _, dir := range c.directions {
+		cells = append(cells, [2]int{pos[0] + dir[0], pos[1] + dir[1]})
+	}
+	affected := make(map[int]bool)
+	affectedIDs := []int{}
+	for _, cell := range cells {
+		if areaID := c.areaAt(areaMap, cell[0], cell[1]); areaID != -1 && !affected[areaID] {
+			affected[areaID] = true
+			affectedIDs = append(affectedIDs, areaID)
+		}
+	}
+	sort.Ints(affectedIDs)
+
+	// 清空受影响区域，重新划分这些格子
+	areas := make([]*Area, len(g.Areas))
+	copy(areas, g.Areas)
+	region := [][2]int{pos}
+	for _, areaID := range affectedIDs {
+		for _, cell := range g.Areas[areaID].Positions {
+			areaMap[cell[0]][cell[1]] = -1
+			region = append(region, cell)
+		}
+		areas[areaID] = &Area{ID: areaID, Treasures: []*TreasureItem{}, Gates: []int{}, Positions: [][2]int{}}
+	}
+	areaMap[pos[0]][pos[1]] = -1
+
+	startArea, endArea := g.StartArea, g.EndArea
+	taken := make(map[int]bool)
+	tempID := -2
+	for _, cell := range region {
+		x, y := cell[0], cell[1]
+		if areaMap[x][y] != -1 || !c.isValidPosition(cell) || c.isGateAt(x, y) || c.isPassageCell(gameMap[x][y]) {
+			continue
+		}
+
+		c.areas = []*Area{}
+		newStart, newEnd := -1, -1
+		c.processCellAsNewArea(x, y, tempID, areaMap, &newStart, &newEnd)
+		tempID--
+		area := c.areas[0]
+
+		// 沿用覆盖到的最小的旧区域ID，没有可用的旧ID时追加新区域
+		areaID := -1
+		for _, p := range area.Positions {
+			if oldID := g.AreaMap[p[0]][p[1]]; oldID != -1 && !taken[oldID] && (areaID == -1 || oldID < areaID) {
+				areaID = oldID
+			}
+		}
+		if areaID == -1 {
+			areaID = len(areas)
+			areas = append(areas, nil)
+		}
+		taken[areaID] = true
+		area.ID = areaID
+		areas[areaID] = area
+		for _, p := range area.Positions {
+			areaMap[p[0]][p[1]] = areaID
+		}
+		if newStart != -1 {
+			startArea = areaID
+		}
+		if newEnd != -1 {
+			endArea = areaID
+		}
+	}
+
+	// 重建与受影响格子相邻的连接点，其余连接点保持不变
+	gateCells := make(map[[2]int]bool)
+	for _, cell := range region {
+		for _, dir := range c.directions {
+			gateCells[[2]int{cell[0] + dir[0], cell[1] + dir[1]}] = true
+		}
+	}
+	gateCells[pos] = true
+	gates := make([]*Gate, 0, len(g.Gates))
+	for _, gate := range g.Gates {
+		if !gateCells[gate.Pos] {
+			gates = append(gates, gate)
+		}
+	}
+	for cell := range gateCells {
+		x, y := cell[0], cell[1]
+		if !c.isValidPosition(cell) || !c.isGateAt(x, y) {
+			continue
+		}
+		connected := make(map[int]bool)
+		areaList := []int{}
+		for _, dir := range c.directions {
+			if areaID := c.areaAt(areaMap, x+dir[0], y+dir[1]); areaID != -1 && !connected[areaID] {
+				connected[areaID] = true
+				areaList = append(areaList, areaID)
+			}
+		}
+		if len(areaList) > 0 {
+			gates = append(gates, c.newGate(x, y, areaList))
+		}
+	}
+
+	// 重建单向通道和传送门的连接，沿用旧图中连接点旁的落脚区域
+	besideAreas := make(map[[3]int]int)
+	for _, area := range areas {
+		if area.Beside != nil {
+			besideAreas[besideKey(*area.Beside, area.Exit)] = area.ID
+		}
+	}
+	links, err := c.buildPassageLinks(areaMap, func(pos [2]int, exit bool) int {
+		areaID, exists := besideAreas[besideKey(pos, exit)]
+		if !exists {
+			areaID = len(areas)
+			areas = append(areas, newBesideArea(areaID, pos, exit))
+			besideAreas[besideKey(pos, exit)] = areaID
+		}
+		// 连接点可能是重建的或者只挨着落脚区域，复制后再加上落脚区域，不修改原图的连接点
+		for i, gate := range gates {
+			if gate.Pos != pos {
+				continue
+			}
+			for _, id := range gate.Areas {
+				if id == areaID {
+					return areaID
+				}
+			}
+			copied := *gate
+			copied.Areas = append(append([]int{}, gate.Areas...), areaID)
+			sort.Ints(copied.Areas)
+			gates[i] = &copied
+			return areaID
+		}
+		gates = append(gates, c.newGate(pos[0], pos[1], []int{areaID}))
+		return areaID
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &Graph{
+		Areas:       areas,
+		StartArea:   startArea,
+		EndArea:     endArea,
+		AreaMap:     areaMap,
+		Gates:       gates,
+		BreakPoints: c.collectBreakPoints(areaMap, areas),
+		Links:       links,
+		Triggers:    c.triggers,
+		source:      c,
+	}
+	graph.indexGates()
+	graph.buildCenterFlyCache(gameMap)
+	return graph, nil
+}
@@ -1,18 +1,22 @@
 package main
 
 import (
-	"container/heap"
+	"context"
 	"fmt"
+	"math"
 	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type State struct {
-	Money             uint8
+	Money             int32 // 金币
 	ATK               int8  // 1字节
 	DEF               int8  // 1字节
 	MDEF              uint8 // 1字节 - 新增魔法防御
-	YellowKeys        int8  // 1字节
-	BlueKeys          int8  // 1字节 - 新增蓝钥匙
+	YellowKeys        int16 // 2字节
+	BlueKeys          int16 // 2字节 - 新增蓝钥匙
 	ConsecutiveFights int8  // 连续战斗次数（未提升攻防时）
 	FightsSinceStart  int8  // 从开始到现在的战斗次数
 
@@ -20,18 +24,123 @@ type State struct {
 	ATKBuys uint8 // 购买攻击的次数
 	DEFBuys uint8 // 购买防御的次数
 
-	HP     int16    // 2字节
-	Action [2]int16 // 新增：当前动作 [damage, pos编码]
+	HP     int16  // 2字节
+	MP     int16  // 魔法值，用于释放技能
+	Action Action // 新增：到达该状态的动作
+
+	// 各部位当前穿戴的装备（ATK/DEF为基础属性，不含装备加成）
+	Equipped [equipSlotCount]*Equipment
+	Ailments uint8 // 当前的状态异常（中毒、衰弱、诅咒）
+
 	// 剪枝相关字段
 
-	DefeatedMonsters   int64 // 8字节
-	CollectedTreasures int64 // 8字节
-	PrevKey            int64 // 新增：前驱状态的key
+	DefeatedMonsters   *ExtendedBitSet // 已击败的连接点（只读，修改时先复制）
+	CollectedTreasures *ExtendedBitSet // 已收集的宝物（只读，修改时先复制）
+	Stairs             *ExtendedBitSet // 到过的楼传楼梯区域（只读，修改时先复制；没有楼传器时为nil）
+	Area               int             // 勇士所在区域（只有存在单向连接时才会变化）
+	Prev               *State          // 前驱状态，用于回溯路径
+}
+
+// 状态的唯一标识
+type StateKey struct {
+	Packed int64  // 已击败怪物、钥匙、金币、购买次数、状态异常的编码
+	Wide   string // 放不进Packed时的完整编码（此时Packed为0）
+	Stairs string // 到过的楼传楼梯区域（楼传只能飞到这些楼梯处）
+	Area   int    // 勇士所在区域
+}
+
+// 状态编码中各字段的位置：已击败怪物占低43位，其余字段按实际位宽排列，共63位
+const (
+	packedDefeatedBits = 43
+	packedYellowKeyBit = 43 // 3位
+	packedBlueKeyBit   = 46 // 2位
+	packedMoneyBit     = 48 // 8位，金币超过255时使用Wide
+	packedATKBuysBit   = 56 // 2位
+	packedDEFBuysBit   = 58 // 2位
+	packedAilmentsBit  = 60 // 3位
+)
+
+// 无损的状态编码：各字段都放得下时使用int64，否则把所有字段按字节编码到Wide中
+func encodeState(defeatedMonsters *ExtendedBitSet, yellowKeys, blueKeys int16, money int32, atkBuys, defBuys uint8, ailments uint8, area int) StateKey {
+	low, wide := defeatedMonsters.Key()
+	if wide == "" && low < 1<<packedDefeatedBits &&
+		yellowKeys >= 0 && yellowKeys <= maxYellowKey && blueKeys >= 0 && blueKeys <= maxBlueKey &&
+		money >= 0 && money <= math.MaxUint8 && atkBuys <= 3 && defBuys <= 3 && ailments < 1<<3 {
+		packed := (int64(ailments) << packedAilmentsBit) | (int64(defBuys) << packedDEFBuysBit) | (int64(atkBuys) << packedATKBuysBit) |
+			(int64(money) << packedMoneyBit) | (int64(blueKeys) << packedBlueKeyBit) |
+			(int64(yellowKeys) << packedYellowKeyBit) | int64(low)
+		return StateKey{Packed: packed, Area: area}
+	}
+
+	buf := []byte{byte(yellowKeys), byte(yellowKeys >> 8), byte(blueKeys), byte(blueKeys >> 8), atkBuys, defBuys, ailments}
+	buf = appendWords(buf, []uint64{uint64(uint32(money)), low})
+	return StateKey{Wide: string(buf) + wide, Area: area}
+}
+
+// 状态的key
+func (s *State) key() StateKey {
+	key := encodeState(s.DefeatedMonsters, s.YellowKeys, s.BlueKeys, s.Money, s.ATKBuys, s.DEFBuys, s.Ailments, s.Area)
+	key.Stairs = stairsKey(s.Stairs)
+	return key
+}
+
+// 路径中的一步动作
+type Action struct {
+	Type    int8     // 动作类型（actionFight等）
+	Damage  int16    // 战斗损失的血量
+	Floor   int      // 动作发生的楼层
+	Pos     [2]int   // 动作发生的位置（战斗、NPC、物品）
+	Skill   int8     // 战斗中使用的技能索引，-1表示未使用技能
+	Ailment uint8    // 解除的状态异常（仅商店解除时）
+	Area    int      // 单向移动到达的区域（仅单向移动时）
+	Parts   []Action // 走廊怪物链中的每一场战斗（仅宏连接点）
+}
+
+// 计入装备加成和衰弱后的攻击力
+func (s *State) TotalATK() int8 {
+	atk, _ := equipmentBonus(s.Equipped)
+	total := int(s.ATK) + int(atk)
+	if s.Ailments&ailmentWeak != 0 {
+		total -= int(weakenATK)
+	}
+	return clampInt8(total)
+}
+
+// 计入装备加成和衰弱后的防御力
+func (s *State) TotalDEF() int8 {
+	_, def := equipmentBonus(s.Equipped)
+	total := int(s.DEF) + int(def)
+	if s.Ailments&ailmentWeak != 0 {
+		total -= int(weakenDEF)
+	}
+	return clampInt8(total)
+}
+
+// 状态a是否支配状态b（两者key相同）：血量、魔法、攻防、魔防和装备都不差，剩下的宝物也不少
+// 各项都相同时战斗次数少的支配战斗次数多的
+func (a *State) dominates(b *State) bool {
+	if a.HP < b.HP || a.MP < b.MP || a.ATK < b.ATK || a.DEF < b.DEF || a.MDEF < b.MDEF {
+		return false
+	}
+	for slot := range a.Equipped {
+		if !equipmentCovers(a.Equipped[slot], b.Equipped[slot]) {
+			return false
+		}
+	}
+	if !b.CollectedTreasures.ContainsAll(a.CollectedTreasures) {
+		return false
+	}
+	if a.HP == b.HP && a.MP == b.MP && a.ATK == b.ATK && a.DEF == b.DEF && a.MDEF == b.MDEF &&
+		a.Equipped == b.Equipped && a.CollectedTreasures.Equal(b.CollectedTreasures) {
+		return a.FightsSinceStart <= b.FightsSinceStart
+	}
+	return true
 }
 
 // 优先队列中的状态项
 type StateItem struct {
-	Key      int64
+	Key      StateKey
+	State    *State
 	Priority int64 // 优先级：越大越优先（血量*1000000 + 金币*1000 - 战斗次数）
 	Index    int   // 在堆中的索引
 }
@@ -70,52 +179,107 @@ func (pq *PriorityQueue) Pop() interface{} {
 }
 
 // 计算状态优先级
-func calculatePriority(hp int16, money uint8, fightsSinceStart int8) int64 {
-	// 优先级 = 血量*1000000 + 金币*1000 - 战斗次数
-	// 这样可以优先选择血量高、金币多、战斗次数少的状态
-	return int64(hp)*1000000 + int64(money)*1000 - int64(fightsSinceStart)
+func calculatePriority(hp, mp int16, money int32, fightsSinceStart int8) int64 {
+	// 优先级 = 血量*1000000000 + 魔法*1000000 + 金币*1000 - 战斗次数
+	// 这样可以优先选择血量高、魔法多、金币多、战斗次数少的状态
+	return int64(hp)*1000000000 + int64(mp)*1000000 + int64(money)*1000 - int64(fightsSinceStart)
 }
 
-// 优化后的主函数 - 使用优先队列
+// 搜索选项
+type SearchOptions struct {
+	Prune   *PruneConfig // 剪枝规则，nil表示不剪枝
+	Workers int          // 并行搜索的协程数，不超过1时顺序搜索
+
+	// 提前停止搜索，停止时返回已找到的最优解
+	Context       context.Context // 取消时停止（nil表示不取消）
+	TimeLimit     time.Duration   // 搜索时间上限，0表示不限制
+	MaxIterations int64           // 扩展的状态数上限，0表示使用默认上限
+	MaxStates     int64           // 加入DP表的状态数上限，0表示不限制
+
+	TopK int // 保留结果最好的K条不同路线（见SearchResult.Routes），不超过1时只保留最优解
+
+	Objective *Objective      // 比较结果的目标，nil表示血量最高、其次金币最多
+	Pareto    []ObjectiveTerm // 非空时收集终点结果在这些指标上的帕累托前沿（见SearchResult.Pareto）
+	Goal      *Goal           // 终点条件，nil表示到达requiredHero的区域且攻防、魔防和钥匙不低于要求
+
+	// 每次找到更优的解时调用（在搜索协程中调用，不应长时间阻塞）
+	OnImprove func(result SearchResult)
+}
+
+// 使用init.go中配置的剪枝规则搜索
 func findOptimalPath(graph *Graph, startHero, requiredHero *HeroItem) SearchResult {
+	return findOptimalPathWithOptions(graph, startHero, requiredHero, &SearchOptions{Prune: pruneConfig})
+}
+
+// 优化后的主函数 - 使用优先队列
+func findOptimalPathWithOptions(graph *Graph, startHero, requiredHero *HeroItem, options *SearchOptions) SearchResult {
 	// 获取所有怪物和宝物
 	initialHP, initialATK, initialDEF, initialYellowKeys, initialBlueKeys, startArea := startHero.HP, startHero.ATK, startHero.DEF, startHero.YellowKeys, startHero.BlueKeys, startHero.AreaID
-	requiredATK, requiredDEF, requiredMDEF, requiredYellowKeys, requiredBlueKeys, endArea := requiredHero.ATK, requiredHero.DEF, requiredHero.MDEF, requiredHero.YellowKeys, requiredHero.BlueKeys, requiredHero.AreaID
-	allMonsters := []*GlobalMonster{}
-	allTreasures := []*GlobalTreasure{}
-
-	for _, area := range graph.Areas {
-		for idx, treasure := range area.Treasures {
-			allTreasures = append(allTreasures, &GlobalTreasure{
-				ID:         fmt.Sprintf("%d-%d", area.ID, idx),
-				AreaID:     area.ID,
-				Type:       treasure.Type,
-				Value:      treasure.Value,
-				OriginalID: treasure.OriginalID,
-			})
+	requiredATK, requiredDEF := requiredHero.ATK, requiredHero.DEF
+	allTreasures := graph.globalTreasures()
+
+	// 终点条件
+	goal := heroGoal(requiredHero)
+	if options.Goal != nil {
+		compiled, err := graph.compileGoal(options.Goal, allTreasures)
+		if err != nil {
+			fmt.Printf("目标无效: %v\n", err)
+			return SearchResult{HP: -1, Path: []Action{}}
 		}
+		goal = compiled
+	}
+	// 以Boss为目标时，连续战斗剪枝改用能打赢Boss的攻防作为要求
+	if boss := goal.requiredBoss(); boss != 0 {
+		requiredATK, requiredDEF = bossTarget(boss, initialHP)
 	}
 
-	for pos, monsterConn := range graph.MonsterConnections {
-		allMonsters = append(allMonsters, &GlobalMonster{
-			Key:            pos,
-			ID:             monsterConn.MonsterID,
-			Monster:        monsterConn.Monster,
-			Pos:            monsterConn.MonsterPos,
-			ConnectedAreas: monsterConn.ConnectedAreas,
-		})
+	// 连接点已按楼层和位置排序，下标即击败状态的位索引
+	allMonsters := graph.Gates
+
+	// 预计算NPC交易的前置条件（需要先击败的怪物索引，构建和加载图时已检查位置都是连接点）
+	npcRequirements := make(map[int][]int)
+	for monsterIdx, monster := range allMonsters {
+		if monster.NPC == nil {
+			continue
+		}
+		for _, requiredPos := range monster.NPC.RequiredDefeated {
+			if required := graph.GateAt(monster.Floor, requiredPos); required != nil {
+				npcRequirements[monsterIdx] = append(npcRequirements[monsterIdx], required.ID)
+			}
+		}
+	}
+
+	// 检查NPC的前置条件是否满足
+	npcConditionMet := func(monsterIdx int, defeatedMonsters *ExtendedBitSet) bool {
+		for _, requiredIdx := range npcRequirements[monsterIdx] {
+			if !defeatedMonsters.IsSet(requiredIdx) {
+				return false
+			}
+		}
+		return true
 	}
 
-	// 按位置坐标排序怪物，确保处理顺序一致
-	sort.Slice(allMonsters, func(i, j int) bool {
-		if allMonsters[i].Pos[0] == allMonsters[j].Pos[0] {
-			return allMonsters[i].Pos[1] < allMonsters[j].Pos[1]
+	// 商店所在的区域和可以解除的状态异常（按固定顺序生成后继状态）
+	cureShopAreas := make(map[int]bool)
+	for _, pos := range cureShopPositions {
+		if check, err := graph.compileGoal(&Goal{Kind: goalReach, Floor: pos[0], Pos: [2]int{pos[1], pos[2]}}, nil); err == nil {
+			for areaID := range check.areas {
+				cureShopAreas[areaID] = true
+			}
 		}
-		return allMonsters[i].Pos[0] < allMonsters[j].Pos[0]
-	})
+	}
+	shopCures := make([]uint8, 0, len(cureShopPrices))
+	for ailment := range cureShopPrices {
+		shopCures = append(shopCures, ailment)
+	}
+	sort.Slice(shopCures, func(i, j int) bool { return shopCures[i] < shopCures[j] })
+
+	// 预计算事件触发器涉及的连接点
+	triggerMasks := buildTriggerMasks(graph)
 
 	// 初始化缓存系统
-	accessCache := NewAccessibilityCache(allMonsters, 100000)
+	accessCache := NewAccessibilityCache(allMonsters, graph.Links, 100000)
+	accessCache.EnableTeleporter(graph.TeleportStairAreas())
 
 	// 预计算宝物-区域映射
 	treasuresByArea := make(map[int][]int)
@@ -124,12 +288,12 @@ func findOptimalPath(graph *Graph, startHero, requiredHero *HeroItem) SearchResu
 	}
 
 	// 计算可收集的宝物（优化版）
-	getCollectibleTreasuresOptimized := func(accessibleAreas map[int]bool, collectedTreasures int64) []int {
+	getCollectibleTreasuresOptimized := func(accessibleAreas map[int]bool, collectedTreasures *ExtendedBitSet) []int {
 		collectible := []int{}
 		for areaID := range accessibleAreas {
 			if treasures, exists := treasuresByArea[areaID]; exists {
 				for _, treasureIdx := range treasures {
-					if !hasBit(collectedTreasures, treasureIdx) {
+					if !collectedTreasures.IsSet(treasureIdx) {
 						collectible = append(collectible, treasureIdx)
 					}
 				}
@@ -138,89 +302,89 @@ func findOptimalPath(graph *Graph, startHero, requiredHero *HeroItem) SearchResu
 		return collectible
 	}
 
-	// 应用宝物效果
-	applyTreasures := func(hp int16, mdef uint8, atk, def, yellowKey, blueKey int8, treasureIndices []int) (int16, uint8, int8, int8, int8, int8) {
-		newHP, newATK, newDEF, newMDEF, newYellowKeys, newBlueKeys := hp, atk, def, mdef, yellowKey, blueKey
-		for _, idx := range treasureIndices {
-			treasure := allTreasures[idx]
-			switch treasure.Type {
-			case treasureDEF:
-				newDEF += treasure.Value
-			case treasureATK:
-				newATK += treasure.Value
-			case treasureHP:
-				newHP += int16(treasure.Value)
-			case treasureYellowKey:
-				newYellowKeys += treasure.Value
-			case treasureBlueKey:
-				newBlueKeys += treasure.Value
-			case treasureMDEF:
-				newMDEF += uint8(treasure.Value)
+	// 存在单向连接或陷阱时，勇士不一定能回到来时的区域，需要按所在区域计算可达性
+	trackArea := graph.HasOneWayLinks() || len(graph.Triggers) > 0
+
+	// 越过连接点后勇士所在的区域（连接点相邻的区域经由该格子互通，取编号最小的一个）
+	gateAreas := make([]int, len(allMonsters))
+	for monsterIdx, monster := range allMonsters {
+		gateAreas[monsterIdx] = monster.Areas[0]
+		for _, areaID := range monster.Areas {
+			if areaID < gateAreas[monsterIdx] {
+				gateAreas[monsterIdx] = areaID
 			}
 		}
-		return newHP, newMDEF, newATK, newDEF, newYellowKeys, newBlueKeys
-	}
-
-	// 修改后的状态编码，包含购买次数信息
-	encodeState := func(defeatedMonsters int64, yellowKeys, blueKeys int8, money uint8, atkBuys, defBuys uint8) int64 {
-		const (
-			yellowKeyBit = 45 // 减少4位为购买次数让出空间
-			blueKeyBit   = 48
-			moneyBit     = 50
-			atkBuysBit   = 56 // 攻击购买次数位置（2位，最多3次）
-			defBuysBit   = 58 // 防御购买次数位置（2位，最多3次）
-			maxYellowKey = 7
-			maxBlueKey   = 3
-			maxMoney     = 63
-		)
-
-		if yellowKeys > maxYellowKey {
-			yellowKeys = maxYellowKey
-		}
-		if blueKeys > maxBlueKey {
-			blueKeys = maxBlueKey
-		}
-		if money > maxMoney {
-			money = maxMoney
-		}
-		if atkBuys > 3 {
-			atkBuys = 3
-		}
-		if defBuys > 3 {
-			defBuys = 3
-		}
-		if defeatedMonsters > (1<<yellowKeyBit)-1 {
-			_ = fmt.Errorf("已击杀怪物数量超过最大限制")
+	}
+
+	// 能顺路收集宝物的区域：存在单向连接时只收集走得回来的区域
+	collectibleAreas := func(defeated, stairs *ExtendedBitSet, area int, accessible map[int]bool) map[int]bool {
+		if trackArea {
+			return accessCache.GetMutualAreas(defeated, stairs, area)
 		}
+		return accessible
+	}
 
-		return (int64(defBuys) << defBuysBit) | (int64(atkBuys) << atkBuysBit) |
-			(int64(money) << moneyBit) | (int64(blueKeys) << blueKeyBit) |
-			(int64(yellowKeys) << yellowKeyBit) | defeatedMonsters
+	// DP表和优先队列（并行搜索时DP表分片加锁）
+	workers := options.Workers
+	if workers < 1 {
+		workers = 1
 	}
+	shardCount := 1
+	if workers > 1 {
+		shardCount = workers * 16
+	}
+	frontier := newSearchFrontier(shardCount, workers)
+	if options.MaxIterations > 0 {
+		frontier.maxIterations = options.MaxIterations
+	}
+	frontier.maxStates = options.MaxStates
 
-	// DP表和优先队列
-	dp := make(map[int64]*State)
-	pq := &PriorityQueue{}
-	heap.Init(pq)
-	inQueue := make(map[int64]bool) // 跟踪哪些状态在队列中
+	// 取消或超时时停止搜索
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if options.TimeLimit > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.TimeLimit)
+		defer cancel()
+	}
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			frontier.stop()
+		case <-finished:
+		}
+	}()
 
 	// 初始状态
-	var initialDefeated int64 = 0
-	var initialCollected int64 = 0
-	initialAccessible := accessCache.GetAccessibleAreas(initialDefeated, startArea)
-	initialCollectible := getCollectibleTreasuresOptimized(initialAccessible, initialCollected)
+	initialDefeated := NewExtendedBitSet(len(allMonsters))
+	// 会被封闭的通道在触发前可以通过
+	for monsterIdx, monster := range allMonsters {
+		if monster.Dynamic != nil && monster.Dynamic.Kind == dynamicClose {
+			initialDefeated.Set(monsterIdx)
+		}
+	}
+	initialDefeated = applyTriggers(triggerMasks, initialDefeated)
+	initialCollected := NewExtendedBitSet(len(allTreasures))
+	initialAccessible := accessCache.GetAccessibleAreas(initialDefeated, nil, startArea)
+	initialAreas := collectibleAreas(initialDefeated, nil, startArea, initialAccessible)
+	initialCollectible := getCollectibleTreasuresOptimized(initialAreas, initialCollected)
 
 	initialMDEF := uint8(0)
-	newHP, newMDEF, newATK, newDEF, newYellowKeys, newBlueKeys := applyTreasures(initialHP, initialMDEF, initialATK, initialDEF, initialYellowKeys, initialBlueKeys, initialCollectible)
-	newInitialCollected := initialCollected
+	newHP, newMDEF, newATK, newDEF, newYellowKeys, newBlueKeys := applyTreasures(allTreasures, initialHP, initialMDEF, initialATK, initialDEF, initialYellowKeys, initialBlueKeys, initialCollectible)
+	initialEquipped := applyEquipment(allTreasures, startHero.Equipped, initialCollectible)
+	initialMP := applyMana(allTreasures, startHero.MP, initialCollectible)
+	newInitialCollected := initialCollected.Copy()
 	for _, idx := range initialCollectible {
-		newInitialCollected = setBit(newInitialCollected, idx)
+		newInitialCollected.Set(idx)
 	}
 
-	initialStateKey := encodeState(initialDefeated, newYellowKeys, newBlueKeys, startHero.Money, 0, 0)
-
 	initialState := &State{
 		HP:                 newHP,
+		MP:                 initialMP,
 		ATK:                newATK,
 		DEF:                newDEF,
 		MDEF:               newMDEF,
@@ -231,82 +395,95 @@ func findOptimalPath(graph *Graph, startHero, requiredHero *HeroItem) SearchResu
 		DEFBuys:            0,
 		DefeatedMonsters:   initialDefeated,
 		CollectedTreasures: newInitialCollected,
-		PrevKey:            0,
-		Action:             [2]int16{0, 0},
+		Stairs:             accessCache.reachStairs(nil, initialAreas),
+		Area:               startArea,
+		Action:             Action{Type: actionNone},
+		Equipped:           initialEquipped,
 		ConsecutiveFights:  0,
 		FightsSinceStart:   0,
 	}
 
-	dp[initialStateKey] = initialState
-	priority := calculatePriority(newHP, startHero.Money, 0)
-	heap.Push(pq, &StateItem{Key: initialStateKey, Priority: priority})
-	inQueue[initialStateKey] = true
+	frontier.relax(0, initialState.key(), initialState)
 
 	// 最优解跟踪
-	var bestResult *SearchResult
-	var bestKey int64
-	var iterations int64
-	var prunedCount int64
-
-	// 优先队列搜索（Dijkstra算法变种）
-	for pq.Len() > 0 && iterations < maxIterations {
-		iterations++
-
-		// 取出优先级最高的状态
-		item := heap.Pop(pq).(*StateItem)
-		stateKey := item.Key
-		delete(inQueue, stateKey)
-
-		state, exists := dp[stateKey]
-		if !exists {
-			continue
+	objective := options.Objective
+	if objective == nil {
+		objective = defaultObjective
+	}
+	var bestMu sync.Mutex // 保护top和pareto
+	top := newTopRoutes(options.TopK, objective, allMonsters)
+	// 分支定界的门槛（见topRoutes.threshold），没有门槛时为noThreshold
+	// 更新最优解时发布，扩展状态时无锁读取
+	var threshold atomic.Int64
+	threshold.Store(noThreshold)
+	var pareto *paretoFront
+	if len(options.Pareto) > 0 {
+		pareto = newParetoFront(options.Pareto, objective, allMonsters)
+	}
+	var prunedCount int64  // 被启发式规则剪掉的状态数，为0时结果保证最优
+	var boundedCount int64 // 血量上限不可能超过当前最优解而丢弃的状态数（不影响最优性）
+	baseAtkDef := startAtkDef(startHero)
+	bound := newHPBound(graph, allTreasures, goal.mandatoryGates(graph, startArea))
+
+	// 第worker个协程扩展一个出队的状态，新状态放入该协程的队列
+	expand := func(worker int, item *StateItem) {
+		state := item.State
+		if !frontier.isCurrent(item.Key, state) {
+			return
+		}
+		relax := func(key StateKey, newState *State) {
+			frontier.relax(worker, key, newState)
 		}
 
-		// 使用缓存获取可达区域
-		accessibleAreas := accessCache.GetAccessibleAreas(state.DefeatedMonsters, startArea)
-
-		// 剪枝检查
-		if shouldPrune(state, requiredATK, requiredDEF, allMonsters, accessibleAreas) {
-			prunedCount++
-			continue
+		// 分支定界：最乐观的情况下也比不过当前最优解
+		if limit := threshold.Load(); limit != noThreshold && int64(bound.upperBound(state)) < limit {
+			atomic.AddInt64(&boundedCount, 1)
+			return
 		}
 
-		// 检查是否到达终点
-		if accessibleAreas[endArea] && state.ATK >= requiredATK && state.DEF >= requiredDEF &&
-			state.YellowKeys >= requiredYellowKeys && state.BlueKeys >= requiredBlueKeys && state.MDEF >= requiredMDEF {
-
-			// 更新最优解
-			if bestResult == nil || state.HP > bestResult.HP ||
-				(state.HP == bestResult.HP && state.Money > bestResult.Money) {
-
-				bestKey = stateKey
-				bestResult = &SearchResult{
-					HP:             state.HP,
-					ATK:            state.ATK,
-					DEF:            state.DEF,
-					MDEF:           state.MDEF,
-					Money:          state.Money,
-					YellowKeys:     state.YellowKeys,
-					BlueKeys:       state.BlueKeys,
-					DefeatedCount:  countBits(state.DefeatedMonsters),
-					CollectedCount: countBits(state.CollectedTreasures),
-				}
+		// 使用缓存获取可达区域
+		accessibleAreas := accessCache.GetAccessibleAreas(state.DefeatedMonsters, state.Stairs, state.Area)
+
+		// 检查是否到达终点（先于启发式剪枝，被剪枝的状态也可能已经满足目标）
+		if goal.met(state, accessibleAreas, allMonsters) {
+
+			// 更新最优解、前K条路线和帕累托前沿
+			bestMu.Lock()
+			if top.offer(state) && top.best() == state && options.OnImprove != nil {
+				improved := newSearchResult(state, allMonsters)
+				improved.Score = top.scores[0]
+				improved.Path = reconstructPath(state)
+				options.OnImprove(improved)
+			}
+			if pareto != nil {
+				pareto.offer(state)
+			} else if limit, full := top.threshold(); full {
+				threshold.Store(int64(limit))
 			}
+			bestMu.Unlock()
 
-			// 找到一个解后可以继续搜索更优解，或者直接返回
-			// 如果想要绝对最优解，继续搜索；如果想要第一个可行解，可以break
-			continue
+			// 到达终点后继续战斗仍可能得到更好的结果，只有启用goal规则时才停止扩展（算作启发式剪枝）
+			if options.Prune.enabled(pruneGoal) {
+				atomic.AddInt64(&prunedCount, 1)
+				return
+			}
+		}
+
+		// 剪枝检查
+		if options.Prune.shouldPrune(state, requiredATK, requiredDEF, baseAtkDef) {
+			atomic.AddInt64(&prunedCount, 1)
+			return
 		}
 
-		// 尝试击败怪物
+		// 尝试击败怪物、与NPC交易或拾取可选物品
 		for monsterIdx, monster := range allMonsters {
-			if hasBit(state.DefeatedMonsters, monsterIdx) {
+			if state.DefeatedMonsters.IsSet(monsterIdx) {
 				continue
 			}
 
 			// 检查怪物是否可达
 			canReachMonster := false
-			for _, areaID := range monster.ConnectedAreas {
+			for _, areaID := range monster.Areas {
 				if accessibleAreas[areaID] {
 					canReachMonster = true
 					break
@@ -317,73 +494,156 @@ func findOptimalPath(graph *Graph, startHero, requiredHero *HeroItem) SearchResu
 				continue
 			}
 
-			// 检查钥匙需求
-			if monster.ID == YellowDoorID && state.YellowKeys <= 0 {
-				continue
-			}
-			if monster.ID == BlueDoorID && state.BlueKeys <= 0 {
-				continue
-			}
-
-			damage := getDamage(state.ATK, state.DEF, monster.ID)
-			if damage >= state.HP {
-				continue
-			}
-
-			newHP := state.HP - damage
-			newMoney := state.Money + monster.Monster.Money
-			newYellowKeys := state.YellowKeys
-			newBlueKeys := state.BlueKeys
-			newDefeated := setBit(state.DefeatedMonsters, monsterIdx)
-
-			// 消耗钥匙
-			if monster.ID == YellowDoorID {
-				newYellowKeys -= 1
-			}
-			if monster.ID == BlueDoorID {
-				newBlueKeys -= 1
+			// 可选的战斗方式：不使用技能，或使用任一MP足够的技能
+			// 走廊怪物链按不被支配的打法展开，此时选项为打法的序号
+			skillOptions := []int{-1}
+			var plans []chainPlan
+			if len(monster.Chain) > 0 {
+				chain := monster.Chain
+				if !accessibleAreas[monster.Areas[0]] {
+					chain = reversedChain(chain)
+				}
+				plans = chainPlans(chain, state.TotalATK(), state.TotalDEF(), state.MP)
+				skillOptions = skillOptions[:0]
+				for planIdx := range plans {
+					skillOptions = append(skillOptions, planIdx)
+				}
+			} else if monster.isMonster() {
+				for skillIdx, skill := range skillList {
+					if state.MP >= skill.MPCost {
+						skillOptions = append(skillOptions, skillIdx)
+					}
+				}
 			}
 
-			// 使用增量更新获取新的可达区域
-			newAccessible := accessCache.GetAccessibleAreasIncremental(
-				state.DefeatedMonsters, monsterIdx, startArea, accessibleAreas)
-
-			newCollectible := getCollectibleTreasuresOptimized(newAccessible, state.CollectedTreasures)
+			for _, skillIdx := range skillOptions {
+				var damage int16
+				var action Action
+				newHP, newMDEF, newATK, newDEF := state.HP, state.MDEF, state.ATK, state.DEF
+				newMP := state.MP
+				newEquipped := state.Equipped
+				newAilments := state.Ailments
+				newMoney := state.Money
+				newYellowKeys := state.YellowKeys
+				newBlueKeys := state.BlueKeys
+
+				if monster.Dynamic != nil {
+					// 机关门和会被封闭的通道由触发器控制，只有机关格需要勇士踩上去
+					if monster.Dynamic.Kind != dynamicTile {
+						continue
+					}
+					action = Action{Type: actionTrigger, Floor: monster.Floor, Pos: monster.Pos}
+				} else if monster.NPC != nil {
+					// NPC交易：检查前置条件和代价
+					if !npcConditionMet(monsterIdx, state.DefeatedMonsters) ||
+						!monster.NPC.canAfford(state.HP, state.MDEF, state.ATK, state.DEF, state.Money, state.YellowKeys, state.BlueKeys) {
+						continue
+					}
+					newHP, newMDEF, newATK, newDEF, newMoney, newYellowKeys, newBlueKeys = monster.NPC.trade(
+						state.HP, state.MDEF, state.ATK, state.DEF, state.Money, state.YellowKeys, state.BlueKeys)
+					action = Action{Type: actionNPC, Floor: monster.Floor, Pos: monster.Pos}
+				} else if monster.Item != nil {
+					// 可选物品：拾取后必须存活
+					newHP, newMDEF, newATK, newDEF, newYellowKeys, newBlueKeys = applyTreasureEffect(monster.Item.Type, monster.Item.Value,
+						state.HP, state.MDEF, state.ATK, state.DEF, state.YellowKeys, state.BlueKeys)
+					if newHP <= 0 {
+						continue
+					}
+					if monster.Item.Type == treasureMP {
+						newMP = clampInt16(int(newMP) + int(monster.Item.Value))
+					}
+					newEquipped = equip(state.Equipped, monster.Item.Equip)
+					newAilments &^= cureAilments[monster.Item.Type]
+					action = Action{Type: actionPickItem, Floor: monster.Floor, Pos: monster.Pos}
+				} else if len(monster.Chain) > 0 {
+					// 走廊中的怪物链：依次战斗，中间没有宝物，攻防不变
+					// 只有没有特殊能力的怪物才会合并，因此只需要选择每场战斗的技能
+					plan := plans[skillIdx]
+					if plan.damage >= int(state.HP) {
+						continue
+					}
+					damage = int16(plan.damage)
+					newHP = state.HP - damage
+					newMP -= plan.mpCost
+					if state.Ailments&ailmentCurse == 0 {
+						newMoney = clampInt32(int(state.Money) + chainMoney(monster))
+					}
+					// 中毒时每场战斗都算一次行动，最后一次在下面统一扣除
+					if state.Ailments&ailmentPoison != 0 {
+						newHP -= poisonDamagePerAction * int16(len(plan.parts)-1)
+					}
+					action = Action{Type: actionFight, Damage: damage, Floor: monster.Floor, Pos: monster.Pos, Skill: -1, Parts: plan.parts}
+				} else {
+					// 检查钥匙需求
+					if monster.MonsterID == YellowDoorID && state.YellowKeys <= 0 {
+						continue
+					}
+					if monster.MonsterID == BlueDoorID && state.BlueKeys <= 0 {
+						continue
+					}
 
-			finalHP, finalMDEF, finalATK, finalDEF, finalYK, finalBK := applyTreasures(newHP, state.MDEF, state.ATK, state.DEF, newYellowKeys, newBlueKeys, newCollectible)
-			finalCollected := state.CollectedTreasures
-			for _, idx := range newCollectible {
-				finalCollected = setBit(finalCollected, idx)
-			}
+					battleATK, battleDEF := state.TotalATK(), state.TotalDEF()
+					if skillIdx >= 0 {
+						battleATK, battleDEF = skillList[skillIdx].battleStats(battleATK, battleDEF)
+						newMP -= skillList[skillIdx].MPCost
+					}
+					damage = getDamage(battleATK, battleDEF, monster.MonsterID)
+					if damage >= state.HP {
+						continue
+					}
 
-			// 计算新的剪枝状态
-			oldAtkDef := state.ATK + state.DEF
-			newAtkDef := finalATK + finalDEF
-			newConsecutiveFights := state.ConsecutiveFights
-			if newAtkDef > oldAtkDef {
-				newConsecutiveFights = 0
-			} else if damage > 0 {
-				newConsecutiveFights++
-			}
+					newHP = state.HP - damage
+					if state.Ailments&ailmentCurse == 0 {
+						newMoney = clampInt32(int(state.Money) + int(monster.Monster.Money))
+					}
+					newAilments |= monster.Monster.Special
 
-			newStateKey := encodeState(newDefeated, finalYK, finalBK, newMoney, state.ATKBuys, state.DEFBuys)
+					// 消耗钥匙
+					if monster.MonsterID == YellowDoorID {
+						newYellowKeys -= 1
+					}
+					if monster.MonsterID == BlueDoorID {
+						newBlueKeys -= 1
+					}
+					action = Action{Type: actionFight, Damage: damage, Floor: monster.Floor, Pos: monster.Pos, Skill: int8(skillIdx)}
+				}
 
-			// 检查是否需要更新状态
-			existingState, exists := dp[newStateKey]
-			shouldUpdate := false
+				// 中毒时走到目标处会持续损失生命（按行动计）
+				if state.Ailments&ailmentPoison != 0 {
+					newHP -= poisonDamagePerAction
+					if newHP <= 0 {
+						continue
+					}
+				}
+				newDefeated := applyTriggers(triggerMasks, setBitExt(state.DefeatedMonsters, monsterIdx))
+
+				// 使用增量更新获取新的可达区域（存在单向连接时从勇士的新位置重新计算）
+				newArea := state.Area
+				var newAccessible map[int]bool
+				if trackArea {
+					newArea = gateAreas[monsterIdx]
+					newAccessible = accessCache.GetAccessibleAreas(newDefeated, state.Stairs, newArea)
+				} else {
+					newAccessible = accessCache.GetAccessibleAreasIncremental(
+						state.DefeatedMonsters, state.Stairs, monsterIdx, state.Area, accessibleAreas)
+				}
 
-			if !exists {
-				shouldUpdate = true
-			} else {
-				// 比较状态优劣：优先血量，其次金币，最后战斗次数
-				newPriority := calculatePriority(finalHP, newMoney, state.FightsSinceStart+1)
-				oldPriority := calculatePriority(existingState.HP, existingState.Money, existingState.FightsSinceStart)
-				shouldUpdate = newPriority > oldPriority
-			}
+				newAreas := collectibleAreas(newDefeated, state.Stairs, newArea, newAccessible)
+				newCollectible := getCollectibleTreasuresOptimized(newAreas, state.CollectedTreasures)
+				newStairs := accessCache.reachStairs(state.Stairs, newAreas)
+
+				finalHP, finalMDEF, finalATK, finalDEF, finalYK, finalBK := applyTreasures(allTreasures, newHP, newMDEF, newATK, newDEF, newYellowKeys, newBlueKeys, newCollectible)
+				finalEquipped := applyEquipment(allTreasures, newEquipped, newCollectible)
+				finalAilments := applyCures(allTreasures, newAilments, newCollectible)
+				finalMP := applyMana(allTreasures, newMP, newCollectible)
+				finalCollected := state.CollectedTreasures.Copy()
+				for _, idx := range newCollectible {
+					finalCollected.Set(idx)
+				}
 
-			if shouldUpdate {
-				newState := &State{
+				finalState := &State{
 					HP:                 finalHP,
+					MP:                 finalMP,
 					ATK:                finalATK,
 					DEF:                finalDEF,
 					MDEF:               finalMDEF,
@@ -394,48 +654,47 @@ func findOptimalPath(graph *Graph, startHero, requiredHero *HeroItem) SearchResu
 					DEFBuys:            state.DEFBuys,
 					DefeatedMonsters:   newDefeated,
 					CollectedTreasures: finalCollected,
-					PrevKey:            stateKey,
-					Action:             [2]int16{int16(damage), int16(monster.Pos[0]<<8 + monster.Pos[1])},
-					ConsecutiveFights:  newConsecutiveFights,
-					FightsSinceStart:   state.FightsSinceStart + 1,
-				}
-				if damage == 0 {
-					newState.FightsSinceStart = state.FightsSinceStart
-				}
-				dp[newStateKey] = newState
-
-				// 加入优先队列（如果不在队列中）
-				if !inQueue[newStateKey] {
-					priority := calculatePriority(finalHP, newMoney, state.FightsSinceStart+1)
-					heap.Push(pq, &StateItem{Key: newStateKey, Priority: priority})
-					inQueue[newStateKey] = true
+					Stairs:             newStairs,
+					Area:               newArea,
+					Prev:               state,
+					Action:             action,
+					Equipped:           finalEquipped,
+					Ailments:           finalAilments,
 				}
-			}
 
-			// 修改后的购买逻辑 - 同时考虑购买ATK和DEF
-			if newMoney >= 40 {
-				// 购买ATK
-				if state.ATKBuys < 3 { // 限制购买次数
-					buyMoneyATK := newMoney - 40
-					buyATK := finalATK + 1
-					newATKBuys := state.ATKBuys + 1
-					buyStateKeyATK := encodeState(newDefeated, finalYK, finalBK, buyMoneyATK, newATKBuys, state.DEFBuys)
-
-					existingBuyStateATK, buyExistsATK := dp[buyStateKeyATK]
-					shouldUpdateBuyATK := false
-
-					if !buyExistsATK {
-						shouldUpdateBuyATK = true
-					} else {
-						newPriorityATK := calculatePriority(finalHP, buyMoneyATK, state.FightsSinceStart+1)
-						oldPriorityATK := calculatePriority(existingBuyStateATK.HP, existingBuyStateATK.Money, existingBuyStateATK.FightsSinceStart)
-						shouldUpdateBuyATK = newPriorityATK > oldPriorityATK
+				// 计算新的剪枝状态（走廊怪物链按其中受伤的战斗场数计）
+				fights := 0
+				if len(action.Parts) > 0 {
+					for _, part := range action.Parts {
+						if part.Damage > 0 {
+							fights++
+						}
 					}
-
-					if shouldUpdateBuyATK {
+				} else if damage > 0 {
+					fights = 1
+				}
+				oldAtkDef := int(state.TotalATK()) + int(state.TotalDEF())
+				newAtkDef := int(finalState.TotalATK()) + int(finalState.TotalDEF())
+				newConsecutiveFights := clampInt8(int(state.ConsecutiveFights) + fights)
+				if newAtkDef > oldAtkDef {
+					newConsecutiveFights = 0
+				}
+				newFightsSinceStart := clampInt8(int(state.FightsSinceStart) + fights)
+
+				finalState.ConsecutiveFights = newConsecutiveFights
+				finalState.FightsSinceStart = newFightsSinceStart
+				relax(finalState.key(), finalState)
+
+				// 修改后的购买逻辑 - 同时考虑购买ATK和DEF
+				if newMoney >= 40 {
+					// 购买ATK
+					if state.ATKBuys < 3 { // 限制购买次数
+						buyMoneyATK := newMoney - 40
+						newATKBuys := state.ATKBuys + 1
 						buyStateATK := &State{
 							HP:                 finalHP,
-							ATK:                buyATK,
+							MP:                 finalMP,
+							ATK:                clampInt8(int(finalATK) + 1),
 							DEF:                finalDEF,
 							MDEF:               finalMDEF,
 							Money:              buyMoneyATK,
@@ -445,45 +704,27 @@ func findOptimalPath(graph *Graph, startHero, requiredHero *HeroItem) SearchResu
 							DEFBuys:            state.DEFBuys,
 							DefeatedMonsters:   newDefeated,
 							CollectedTreasures: finalCollected,
-							PrevKey:            newStateKey,
-							Action:             [2]int16{-1, -1}, // 购买ATK
+							Stairs:             newStairs,
+							Area:               newArea,
+							Prev:               finalState,
+							Action:             Action{Type: actionBuyATK},
+							Equipped:           finalEquipped,
+							Ailments:           finalAilments,
 							ConsecutiveFights:  0,
 							FightsSinceStart:   state.FightsSinceStart + 1,
 						}
-
-						dp[buyStateKeyATK] = buyStateATK
-
-						if !inQueue[buyStateKeyATK] {
-							buyPriorityATK := calculatePriority(finalHP, buyMoneyATK, state.FightsSinceStart+1)
-							heap.Push(pq, &StateItem{Key: buyStateKeyATK, Priority: buyPriorityATK})
-							inQueue[buyStateKeyATK] = true
-						}
-					}
-				}
-
-				// 购买DEF
-				if state.DEFBuys < 3 { // 限制购买次数
-					buyMoneyDEF := newMoney - 40
-					buyDEF := finalDEF + 1
-					newDEFBuys := state.DEFBuys + 1
-					buyStateKeyDEF := encodeState(newDefeated, finalYK, finalBK, buyMoneyDEF, state.ATKBuys, newDEFBuys)
-
-					existingBuyStateDEF, buyExistsDEF := dp[buyStateKeyDEF]
-					shouldUpdateBuyDEF := false
-
-					if !buyExistsDEF {
-						shouldUpdateBuyDEF = true
-					} else {
-						newPriorityDEF := calculatePriority(finalHP, buyMoneyDEF, state.FightsSinceStart+1)
-						oldPriorityDEF := calculatePriority(existingBuyStateDEF.HP, existingBuyStateDEF.Money, existingBuyStateDEF.FightsSinceStart)
-						shouldUpdateBuyDEF = newPriorityDEF > oldPriorityDEF
+						relax(buyStateATK.key(), buyStateATK)
 					}
 
-					if shouldUpdateBuyDEF {
+					// 购买DEF
+					if state.DEFBuys < 3 { // 限制购买次数
+						buyMoneyDEF := newMoney - 40
+						newDEFBuys := state.DEFBuys + 1
 						buyStateDEF := &State{
 							HP:                 finalHP,
+							MP:                 finalMP,
 							ATK:                finalATK,
-							DEF:                buyDEF,
+							DEF:                clampInt8(int(finalDEF) + 1),
 							MDEF:               finalMDEF,
 							Money:              buyMoneyDEF,
 							YellowKeys:         finalYK,
@@ -492,36 +733,144 @@ func findOptimalPath(graph *Graph, startHero, requiredHero *HeroItem) SearchResu
 							DEFBuys:            newDEFBuys,
 							DefeatedMonsters:   newDefeated,
 							CollectedTreasures: finalCollected,
-							PrevKey:            newStateKey,
-							Action:             [2]int16{-2, -2}, // 购买DEF
+							Stairs:             newStairs,
+							Area:               newArea,
+							Prev:               finalState,
+							Action:             Action{Type: actionBuyDEF},
+							Equipped:           finalEquipped,
+							Ailments:           finalAilments,
 							ConsecutiveFights:  0,
 							FightsSinceStart:   state.FightsSinceStart + 1,
 						}
+						relax(buyStateDEF.key(), buyStateDEF)
+					}
+				}
+			}
+		}
 
-						dp[buyStateKeyDEF] = buyStateDEF
+		// 在商店解除状态异常（需要能到达商店）
+		atCureShop := false
+		for areaID := range cureShopAreas {
+			if accessibleAreas[areaID] {
+				atCureShop = true
+				break
+			}
+		}
+		for _, ailment := range shopCures {
+			price := cureShopPrices[ailment]
+			if !atCureShop || state.Ailments&ailment == 0 || state.Money < price {
+				continue
+			}
+			curedState := *state
+			curedState.Ailments &^= ailment
+			curedState.Money -= price
+			curedState.Prev = state
+			curedState.Action = Action{Type: actionCure, Ailment: ailment}
+			relax(curedState.key(), &curedState)
+		}
 
-						if !inQueue[buyStateKeyDEF] {
-							buyPriorityDEF := calculatePriority(finalHP, buyMoneyDEF, state.FightsSinceStart+1)
-							heap.Push(pq, &StateItem{Key: buyStateKeyDEF, Priority: buyPriorityDEF})
-							inQueue[buyStateKeyDEF] = true
-						}
+		// 经单向通道或传送门进入回不来的区域，并收集那里能拿到的宝物
+		if trackArea {
+			mutualAreas := accessCache.GetMutualAreas(state.DefeatedMonsters, state.Stairs, state.Area)
+			for _, link := range graph.Links {
+				if !accessibleAreas[link.From] || mutualAreas[link.To] {
+					continue
+				}
+				movedState := *state
+				if state.Ailments&ailmentPoison != 0 {
+					movedState.HP -= poisonDamagePerAction
+					if movedState.HP <= 0 {
+						continue
 					}
 				}
+				movedAreas := accessCache.GetMutualAreas(state.DefeatedMonsters, state.Stairs, link.To)
+				movedCollectible := getCollectibleTreasuresOptimized(movedAreas, state.CollectedTreasures)
+				movedState.HP, movedState.MDEF, movedState.ATK, movedState.DEF, movedState.YellowKeys, movedState.BlueKeys = applyTreasures(
+					allTreasures, movedState.HP, movedState.MDEF, movedState.ATK, movedState.DEF, movedState.YellowKeys, movedState.BlueKeys, movedCollectible)
+				movedState.Equipped = applyEquipment(allTreasures, movedState.Equipped, movedCollectible)
+				movedState.Ailments = applyCures(allTreasures, movedState.Ailments, movedCollectible)
+				movedState.MP = applyMana(allTreasures, movedState.MP, movedCollectible)
+				movedState.CollectedTreasures = state.CollectedTreasures.Copy()
+				for _, idx := range movedCollectible {
+					movedState.CollectedTreasures.Set(idx)
+				}
+				movedState.Stairs = accessCache.reachStairs(state.Stairs, movedAreas)
+				movedState.Area = link.To
+				movedState.Prev = state
+				movedState.Action = Action{Type: actionMove, Area: link.To}
+				relax(movedState.key(), &movedState)
 			}
 		}
 	}
 
-	if iterations >= maxIterations {
-		fmt.Println("max iterations reached")
+	// 每个协程扩展自己队列中的状态，空闲时窃取其他协程的状态，所有状态都扩展完时结束
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for {
+				item, ok := frontier.pop(worker)
+				if !ok {
+					return
+				}
+				expand(worker, item)
+				frontier.done()
+			}
+		}(i)
 	}
+	wg.Wait()
 
-	if bestResult != nil {
-		bestResult.Path = reconstructPath(dp, bestKey)
-		return *bestResult
-	} else {
+	interrupted := frontier.interrupted()
+	optimal := prunedCount == 0 && !interrupted
+	bestState := top.best()
+	if bestState == nil {
 		return SearchResult{
-			HP:   -1,
-			Path: []int16{},
+			HP:          -1,
+			Path:        []Action{},
+			Optimal:     optimal,
+			Interrupted: interrupted,
 		}
 	}
+
+	bestResult := newSearchResult(bestState, allMonsters)
+	bestResult.Score = top.scores[0]
+	bestResult.Path = reconstructPath(bestState)
+	bestResult.Optimal = optimal
+	bestResult.Interrupted = interrupted
+	if options.TopK > 1 {
+		for i, state := range top.states {
+			route := newSearchResult(state, allMonsters)
+			route.Score = top.scores[i]
+			route.Path = reconstructPath(state)
+			bestResult.Routes = append(bestResult.Routes, route)
+		}
+	}
+	if pareto != nil {
+		for _, state := range pareto.sorted() {
+			point := newSearchResult(state, allMonsters)
+			point.Score = objective.score(state, allMonsters)
+			point.Path = reconstructPath(state)
+			bestResult.Pareto = append(bestResult.Pareto, point)
+		}
+	}
+	return bestResult
+}
+
+// 到达终点的状态对应的搜索结果（不含路径）
+func newSearchResult(state *State, gates []*Gate) SearchResult {
+	return SearchResult{
+		HP:             state.HP,
+		MP:             state.MP,
+		ATK:            state.TotalATK(),
+		DEF:            state.TotalDEF(),
+		MDEF:           state.MDEF,
+		Money:          state.Money,
+		YellowKeys:     state.YellowKeys,
+		BlueKeys:       state.BlueKeys,
+		DefeatedCount:  defeatedCount(state.DefeatedMonsters, gates),
+		CollectedCount: state.CollectedTreasures.Count(),
+		Equipped:       state.Equipped,
+		Ailments:       state.Ailments,
+	}
 }
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 测试用的小地图：起点(5,3)，终点(0,3)
+var sampleMap = [][]int{
+	{1, 1, 1, 0, 1, 1, 1},
+	{1, 31, 0, 210, 0, 42, 1},
+	{1, 93, 1, 1, 1, 81, 1},
+	{1, 213, 1, 41, 0, 0, 1},
+	{1, 0, 21, 0, 212, 32, 1},
+	{1, 1, 1, 0, 1, 1, 1},
+}
+
+// 转换地图，失败时终止测试
+func convertForTest(t testing.TB, m [][]int, start, end [2]int) *Graph {
+	t.Helper()
+	graph, err := NewMapToGraphConverter(m, treasureMap, monsterMap, npcMap, start, end).Convert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return graph
+}
+
+// 金币、钥匙和属性超出字段范围时取边界值，不会回绕
+func TestStatsSaturate(t *testing.T) {
+	hp, mdef, atk, def, yellowKeys, blueKeys := applyTreasureEffect(treasureYellowKey, 10, 32760, 250, 125, -126, 120, 0)
+	if yellowKeys != 130 {
+		t.Errorf("黄钥匙 = %d, 期望130（钥匙不再限制在127以内）", yellowKeys)
+	}
+	if _, _, _, _, _, blueKeys := applyTreasureEffect(treasureBlueKey, 10, hp, mdef, atk, def, 0, 32760); blueKeys != 32767 {
+		t.Errorf("蓝钥匙 = %d, 期望32767", blueKeys)
+	}
+	hp, mdef, atk, def, yellowKeys, blueKeys = applyTreasureEffect(treasureHP, 100, hp, mdef, atk, def, yellowKeys, blueKeys)
+	hp, mdef, atk, def, yellowKeys, blueKeys = applyTreasureEffect(treasureMDEF, 10, hp, mdef, atk, def, yellowKeys, blueKeys)
+	hp, mdef, atk, def, yellowKeys, blueKeys = applyTreasureEffect(treasureATK, 5, hp, mdef, atk, def, yellowKeys, blueKeys)
+	hp, mdef, atk, def, _, _ = applyTreasureEffect(treasureDEF, -5, hp, mdef, atk, def, yellowKeys, blueKeys)
+	if hp != 32767 || mdef != 255 || atk != 127 || def != -128 {
+		t.Errorf("HP=%d 魔防=%d 攻击=%d 防御=%d, 期望取边界值", hp, mdef, atk, def)
+	}
+
+	initDamageCache()
+	m := [][]int{
+		{1, 1, 1, 1, 1},
+		{1, 0, 210, 0, 1},
+		{1, 1, 1, 1, 1},
+	}
+	graph := convertForTest(t, m, [2]int{1, 1}, [2]int{1, 3})
+	for _, c := range []struct{ money, want int32 }{
+		{254, 257}, // 金币超过255时不截断
+		{math.MaxInt32 - 1, math.MaxInt32},
+	} {
+		result := findOptimalPathWithOptions(graph, &HeroItem{HP: 400, ATK: 10, DEF: 6, Money: c.money, AreaID: graph.StartArea},
+			&HeroItem{ATK: 10, DEF: 6, AreaID: graph.EndArea}, &SearchOptions{Prune: &PruneConfig{Exact: true},
+				Objective: &Objective{Terms: []ObjectiveTerm{{Metric: metricMoney, Weight: 1}}}})
+		if result.Money != c.want {
+			t.Errorf("初始金币%d: 金币 = %d, 期望%d", c.money, result.Money, c.want)
+		}
+	}
+}
+
+// 收益会使属性超出字段范围的交易不能进行
+func TestNPCTradeOverflow(t *testing.T) {
+	npc := &NPC{Cost: NPCTrade{Money: 10}, Gain: NPCTrade{Money: 100, YellowKeys: 1}}
+	if !npc.canAfford(100, 0, 10, 6, 170, 1, 0) {
+		t.Error("金币170时交易后为260, 应该可以交易")
+	}
+	if npc.canAfford(100, 0, 10, 6, math.MaxInt32-50, 1, 0) {
+		t.Error("交易后金币超出int32范围不应交易")
+	}
+	if !npc.canAfford(100, 0, 10, 6, 160, 127, 0) {
+		t.Error("黄钥匙127时交易后为128, 应该可以交易")
+	}
+	if npc.canAfford(100, 0, 10, 6, 160, math.MaxInt16, 0) {
+		t.Error("交易后黄钥匙超出int16范围不应交易")
+	}
+}
+
+// 超过旧编码上限（45个连接点、7把黄钥匙、63金币）的状态也要得到不同的key
+func TestEncodeStateLossless(t *testing.T) {
+	low, high := NewExtendedBitSet(64), NewExtendedBitSet(64)
+	low.Set(3)
+	high.Set(3)
+	high.Set(50)
+	cases := []struct {
+		name string
+		a, b StateKey
+	}{
+		{"连接点", encodeState(low, 1, 1, 0, 0, 0, 0, 0), encodeState(high, 1, 1, 0, 0, 0, 0, 0)},
+		{"黄钥匙", encodeState(low, 8, 1, 0, 0, 0, 0, 0), encodeState(low, 9, 1, 0, 0, 0, 0, 0)},
+		{"蓝钥匙", encodeState(low, 1, 4, 0, 0, 0, 0, 0), encodeState(low, 1, 5, 0, 0, 0, 0, 0)},
+		{"金币", encodeState(low, 1, 1, 64, 0, 0, 0, 0), encodeState(low, 1, 1, 100, 0, 0, 0, 0)},
+		{"金币超过255", encodeState(low, 1, 1, 255, 0, 0, 0, 0), encodeState(low, 1, 1, 300, 0, 0, 0, 0)},
+		{"金币相差256", encodeState(low, 1, 1, 44, 0, 0, 0, 0), encodeState(low, 1, 1, 300, 0, 0, 0, 0)},
+	}
+	for _, c := range cases {
+		if c.a == c.b {
+			t.Errorf("%s不同的状态得到了相同的key", c.name)
+		}
+	}
+	if encodeState(high, 9, 5, 100, 1, 2, 0, 3) != encodeState(high.Copy(), 9, 5, 100, 1, 2, 0, 3) {
+		t.Error("相同的状态得到了不同的key")
+	}
+}
+
+// 一条走廊上有50扇黄门，需要50把钥匙
+func TestSearchManyGatesAndKeys(t *testing.T) {
+	initDamageCache()
+	row := []int{0}
+	for i := 0; i < 50; i++ {
+		row = append(row, YellowDoorID, 0)
+	}
+	wall := make([]int, len(row))
+	for i := range wall {
+		wall[i] = 1
+	}
+	m := [][]int{wall, row, wall}
+	graph := convertForTest(t, m, [2]int{1, 0}, [2]int{1, len(row) - 1})
+	if len(graph.Gates) != 50 {
+		t.Fatalf("连接点数 = %d, 期望50", len(graph.Gates))
+	}
+
+	options := &SearchOptions{Prune: &PruneConfig{Exact: true}}
+	for _, keys := range []int16{200, 49} {
+		result := findOptimalPathWithOptions(graph, &HeroItem{HP: 100, ATK: 10, DEF: 6, AreaID: graph.StartArea, YellowKeys: keys},
+			&HeroItem{AreaID: graph.EndArea}, options)
+		if keys < 50 {
+			if result.HP != -1 {
+				t.Errorf("%d把钥匙不够打开50扇门，却找到了解", keys)
+			}
+			continue
+		}
+		if result.HP != 100 || result.YellowKeys != keys-50 || result.DefeatedCount != 50 {
+			t.Errorf("%d把钥匙: HP=%d 黄钥匙=%d 击败%d, 期望HP=100 黄钥匙=%d 击败50",
+				keys, result.HP, result.YellowKeys, result.DefeatedCount, keys-50)
+		}
+	}
+}
+
+// 样例地图上的搜索用例
+type sampleCase struct {
+	name       string
+	m          [][]int
+	start, end [2]int
+	hp         int16
+}
+
+var sampleCases = []sampleCase{
+	{"小地图", sampleMap, [2]int{5, 3}, [2]int{0, 3}, 400},
+	{"主地图上半部分", gameMap[:17], [2]int{13, 6}, [2]int{0, 6}, 230},
+	{"主地图上半部分高血量", gameMap[:17], [2]int{13, 6}, [2]int{0, 6}, 500},
+}
+
+// 在样例上搜索，起点属性与主程序相同
+func searchSample(t testing.TB, graph *Graph, c sampleCase, options *SearchOptions) SearchResult {
+	t.Helper()
+	return findOptimalPathWithOptions(graph,
+		&HeroItem{HP: c.hp, ATK: 10, DEF: 6, AreaID: graph.StartArea, YellowKeys: 1, BlueKeys: 1},
+		&HeroItem{ATK: 11, DEF: 7, AreaID: graph.EndArea}, options)
+}
+
+// 并行搜索和顺序搜索的结果相同
+func TestParallelMatchesSequential(t *testing.T) {
+	initDamageCache()
+	for _, c := range sampleCases {
+		graph := convertForTest(t, c.m, c.start, c.end)
+		for _, prune := range []*PruneConfig{{Exact: true}, pruneConfig} {
+			sequential := searchSample(t, graph, c, &SearchOptions{Prune: prune, Workers: 1})
+			parallel := searchSample(t, graph, c, &SearchOptions{Prune: prune, Workers: 4})
+			if sequential.HP != parallel.HP || sequential.Money != parallel.Money || sequential.Optimal != parallel.Optimal {
+				t.Errorf("%s(精确=%v): 顺序 HP=%d 金币=%d 最优=%v, 并行 HP=%d 金币=%d 最优=%v", c.name, prune.Exact,
+					sequential.HP, sequential.Money, sequential.Optimal, parallel.HP, parallel.Money, parallel.Optimal)
+			}
+		}
+	}
+}
+
+// 血量上限只丢弃不可能进入前K的状态：关掉分支定界（收集帕累托前沿时不使用）后结果相同
+func TestHPBoundKeepsBetterStates(t *testing.T) {
+	initDamageCache()
+	for _, c := range sampleCases {
+		graph := convertForTest(t, c.m, c.start, c.end)
+		for _, topK := range []int{1, 3} {
+			bounded := searchSample(t, graph, c, &SearchOptions{Prune: &PruneConfig{Exact: true}, TopK: topK})
+			unbounded := searchSample(t, graph, c, &SearchOptions{Prune: &PruneConfig{Exact: true}, TopK: topK,
+				Pareto: []ObjectiveTerm{{Metric: metricHP, Weight: 1}}})
+			if compareScores(bounded.Score, unbounded.Score) != 0 {
+				t.Errorf("%s(K=%d): 定界 %v, 不定界 %v", c.name, topK, bounded.Score, unbounded.Score)
+			}
+			if len(bounded.Routes) != len(unbounded.Routes) {
+				t.Errorf("%s(K=%d): 定界%d条路线, 不定界%d条", c.name, topK, len(bounded.Routes), len(unbounded.Routes))
+				continue
+			}
+			for i := range bounded.Routes {
+				if compareScores(bounded.Routes[i].Score, unbounded.Routes[i].Score) != 0 {
+					t.Errorf("%s(K=%d): 第%d条路线 定界 %v, 不定界 %v", c.name, topK, i+1, bounded.Routes[i].Score, unbounded.Routes[i].Score)
+				}
+			}
+		}
+	}
+}
+
+// 楼传只能飞到到过的楼梯处：终点在高层的怪物后面时，有没有楼传器都要先打过怪物
+func TestTeleportNeedsReachedStairs(t *testing.T) {
+	initDamageCache()
+	floors := [][][]int{
+		{{1, 1, 1, 1}, {1, 0, UpStairsID, 1}, {1, 1, 1, 1}},
+		{{1, 1, 1, 1, 1, 1, 1}, {1, DownStairsID, 0, 210, 0, UpStairsID, 1}, {1, 1, 1, 1, 1, 1, 1}},
+		{{1, 1, 1, 1, 1}, {1, DownStairsID, 0, 0, 1}, {1, 1, 1, 1, 1}},
+	}
+	search := func(teleporter *Teleporter) SearchResult {
+		tower := &Tower{StartFloor: 0, Start: [2]int{1, 1}, EndFloor: 2, End: [2]int{1, 3}, Teleporter: teleporter}
+		for _, m := range floors {
+			tower.Floors = append(tower.Floors, &Floor{GameMap: m})
+		}
+		graph, err := NewTowerToGraphConverter(tower, treasureMap, monsterMap, npcMap).Convert()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return findOptimalPathWithOptions(graph, &HeroItem{HP: 400, ATK: 10, DEF: 6, AreaID: graph.StartArea},
+			&HeroItem{ATK: 10, DEF: 6, AreaID: graph.EndArea}, &SearchOptions{Prune: &PruneConfig{Exact: true}})
+	}
+
+	walked := search(nil)
+	if walked.HP <= 0 || walked.HP >= 400 {
+		t.Fatalf("没有楼传时 HP=%d, 期望打过怪物后到达终点", walked.HP)
+	}
+	if flown := search(&Teleporter{}); flown.HP != walked.HP {
+		t.Errorf("有楼传时 HP=%d, 没有楼传时 HP=%d, 楼传不应越过怪物", flown.HP, walked.HP)
+	}
+}
+
+// goal规则在第一次到达终点时停止扩展，可能错过更好的结果，不能报告为最优
+func TestGoalRuleIsNotOptimal(t *testing.T) {
+	initDamageCache()
+	m := [][]int{
+		{1, 1, 1, 1, 1, 1, 1, 1, 1},
+		{1, 0, 27, 28, 210, 31, 31, 31, 1},
+		{1, 1, 1, 1, 1, 1, 1, 1, 1},
+	}
+	c := sampleCase{name: "起点即终点", m: m, start: [2]int{1, 1}, end: [2]int{1, 1}, hp: 400}
+	graph := convertForTest(t, c.m, c.start, c.end)
+	exact := searchSample(t, graph, c, &SearchOptions{Prune: &PruneConfig{Exact: true}})
+	heuristic := searchSample(t, graph, c, &SearchOptions{Prune: pruneConfig})
+	if exact.HP <= heuristic.HP {
+		t.Fatalf("精确搜索 HP=%d, 默认规则 HP=%d, 期望精确搜索打怪拿血瓶后更高", exact.HP, heuristic.HP)
+	}
+	if !exact.Optimal || heuristic.Optimal {
+		t.Errorf("精确搜索 最优=%v, 默认规则 最优=%v", exact.Optimal, heuristic.Optimal)
+	}
+}
+
+// 各项都不差的状态支配另一个状态，有一项更差就不支配
+func TestStateDominates(t *testing.T) {
+	collected := NewExtendedBitSet(8)
+	state := func(hp, mp int16, atk int8) *State {
+		return &State{HP: hp, MP: mp, ATK: atk, DEF: 6, CollectedTreasures: collected}
+	}
+	best := state(100, 10, 12)
+	if !best.dominates(state(90, 5, 11)) {
+		t.Error("各项都更好的状态应该支配")
+	}
+	for _, other := range []*State{state(90, 20, 12), state(90, 10, 13)} {
+		if best.dominates(other) || other.dominates(best) {
+			t.Errorf("HP=%d MP=%d 攻击=%d 与 HP=100 MP=10 攻击=12 应该互不支配", other.HP, other.MP, other.ATK)
+		}
+	}
+	more := collected.Copy()
+	more.Set(0)
+	richer := state(100, 10, 12)
+	richer.CollectedTreasures = more
+	if richer.dominates(best) {
+		t.Error("已经拿走更多宝物的状态不应支配剩下宝物更多的状态")
+	}
+}
+
+// 血量较少但保留了MP的状态不能被淘汰：后面的怪物只有用二倍斩才打得动
+func TestFrontierKeepsLowerHPWithMoreMP(t *testing.T) {
+	m := [][]int{
+		{1, 1, 1, 1, 1, 1, 1},
+		{1, 0, 213, 0, 214, 0, 1},
+		{1, 1, 1, 1, 1, 1, 1},
+	}
+	result := searchMap(t, m, [2]int{1, 1}, [2]int{1, 5}, HeroItem{HP: 1000, MP: 10, ATK: 10, DEF: 6}, HeroItem{})
+	want := 1000 - getDamage(10, 6, 213) - getDamage(20, 6, 214)
+	if result.HP != want || len(result.Path) != 2 || result.Path[0].Skill != -1 || result.Path[1].Skill < 0 {
+		t.Errorf("HP=%d 路径=%+v, 期望第一场不用技能、第二场用二倍斩, HP=%d", result.HP, result.Path, want)
+	}
+}
+
+// 血量较少但金币更多的状态可以买攻击，最终结果更好
+func TestFrontierKeepsLowerHPWithMoreMoney(t *testing.T) {
+	m := [][]int{
+		{1, 1, 1, 1, 1, 1},
+		{1, 0, 0, 210, 0, 1},
+		{1, 1, 201, 1, 1, 1},
+		{1, 1, 1, 1, 1, 1},
+	}
+	result := searchMap(t, m, [2]int{1, 1}, [2]int{1, 4}, HeroItem{HP: 400, ATK: 10, DEF: 17, Money: 38}, HeroItem{})
+	want := 400 - getDamage(10, 17, 201) - getDamage(11, 17, 210)
+	if result.HP != want || countActions(result.Path, actionBuyATK) != 1 {
+		t.Errorf("HP=%d 路径=%+v, 期望打小怪攒够金币买攻击后 HP=%d", result.HP, result.Path, want)
+	}
+}
+
+// 拾取后攻击力下降的可选物品不能让血量上限变低，否则会丢掉更好的状态
+func TestHPBoundIgnoresNegativeGains(t *testing.T) {
+	initDamageCache()
+	treasures := make(map[int]*Treasure, len(treasureMap)+1)
+	for id, treasure := range treasureMap {
+		treasures[id] = treasure
+	}
+	const cursedSword = 99
+	treasures[cursedSword] = &Treasure{Type: treasureATK, Value: -100, Optional: true}
+	m := [][]int{
+		{1, 1, 1, 0, 1, 1, 1},
+		{1, 31, 0, 210, 0, 42, 1},
+		{1, 93, 1, 1, cursedSword, 81, 1},
+		{1, 213, 1, 41, 0, 0, 1},
+		{1, 0, 21, 0, 212, 32, 1},
+		{1, 1, 1, 0, 1, 1, 1},
+	}
+	graph, err := NewMapToGraphConverter(m, treasures, monsterMap, npcMap, [2]int{5, 3}, [2]int{0, 3}).Convert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := sampleCase{name: "诅咒剑", m: m, start: [2]int{5, 3}, end: [2]int{0, 3}, hp: 400}
+	bound := newHPBound(graph, graph.globalTreasures(), graph.MandatoryGateIDs(graph.StartArea, graph.EndArea))
+	initial := &State{HP: c.hp, ATK: 10, DEF: 6, DefeatedMonsters: NewExtendedBitSet(len(graph.Gates)),
+		CollectedTreasures: NewExtendedBitSet(len(graph.globalTreasures()))}
+	bounded := searchSample(t, graph, c, &SearchOptions{Prune: &PruneConfig{Exact: true}})
+	unbounded := searchSample(t, graph, c, &SearchOptions{Prune: &PruneConfig{Exact: true},
+		Pareto: []ObjectiveTerm{{Metric: metricHP, Weight: 1}}})
+	if upper := bound.upperBound(initial); upper < int(unbounded.HP) {
+		t.Errorf("初始状态的血量上限 = %d, 低于能达到的 HP=%d", upper, unbounded.HP)
+	}
+	if bounded.HP != unbounded.HP || !bounded.Optimal {
+		t.Errorf("定界 HP=%d 最优=%v, 不定界 HP=%d", bounded.HP, bounded.Optimal, unbounded.HP)
+	}
+}
+
+// 记录OnImprove收到的结果
+type improvements struct {
+	mu      sync.Mutex
+	results []SearchResult
+}
+
+func (r *improvements) record(result SearchResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, result)
+}
+
+// 每次结果严格变好时调用一次OnImprove，最后一次即最终结果
+func TestOnImproveStrictlyBetter(t *testing.T) {
+	initDamageCache()
+	c := sampleCases[2]
+	graph := convertForTest(t, c.m, c.start, c.end)
+	for _, workers := range []int{1, 4} {
+		var seen improvements
+		result := searchSample(t, graph, c, &SearchOptions{Prune: &PruneConfig{Exact: true}, Workers: workers, OnImprove: seen.record})
+		if len(seen.results) == 0 {
+			t.Fatalf("%d个协程: 没有调用OnImprove", workers)
+		}
+		for i := 1; i < len(seen.results); i++ {
+			if compareScores(seen.results[i].Score, seen.results[i-1].Score) <= 0 {
+				t.Errorf("%d个协程: 第%d次得分%v不比上一次%v好", workers, i+1, seen.results[i].Score, seen.results[i-1].Score)
+			}
+		}
+		if last := seen.results[len(seen.results)-1]; compareScores(last.Score, result.Score) != 0 || last.HP != result.HP {
+			t.Errorf("%d个协程: 最后一次改进 %v, 最终结果 %v", workers, last.Score, result.Score)
+		}
+	}
+}
+
+// 取消或预算用尽时返回已找到的最优解，并标记为提前停止、不保证最优
+func TestSearchStopsEarly(t *testing.T) {
+	initDamageCache()
+	c := sampleCases[2]
+	graph := convertForTest(t, c.m, c.start, c.end)
+	exact := &PruneConfig{Exact: true}
+	full := searchSample(t, graph, c, &SearchOptions{Prune: exact})
+
+	// 找到第一个解后取消
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var seen improvements
+	cancelled := searchSample(t, graph, c, &SearchOptions{Prune: exact, Context: ctx, OnImprove: func(result SearchResult) {
+		seen.record(result)
+		cancel()
+	}})
+	checkStoppedEarly(t, "取消", cancelled, seen.results, full)
+
+	// 预算从小到大翻倍，直到找到解但还没有搜完
+	budgets := map[string]func(limit int64) *SearchOptions{
+		"MaxIterations": func(limit int64) *SearchOptions { return &SearchOptions{Prune: exact, MaxIterations: limit} },
+		"MaxStates":     func(limit int64) *SearchOptions { return &SearchOptions{Prune: exact, MaxStates: limit} },
+	}
+	for name, options := range budgets {
+		for limit := int64(1); ; limit *= 2 {
+			var seen improvements
+			opts := options(limit)
+			opts.OnImprove = seen.record
+			result := searchSample(t, graph, c, opts)
+			if !result.Interrupted {
+				t.Errorf("%s=%d: 没有找到解之前就搜完了", name, limit)
+				break
+			}
+			if result.HP > 0 {
+				checkStoppedEarly(t, name, result, seen.results, full)
+				break
+			}
+		}
+	}
+
+	// 时间用尽：可能还没有找到解
+	timed := searchSample(t, graph, c, &SearchOptions{Prune: exact, TimeLimit: time.Nanosecond})
+	if !timed.Interrupted || timed.Optimal {
+		t.Errorf("时间用尽: 提前停止=%v 最优=%v", timed.Interrupted, timed.Optimal)
+	}
+}
+
+// 提前停止的结果是停止前找到的最优解
+func checkStoppedEarly(t *testing.T, name string, result SearchResult, seen []SearchResult, full SearchResult) {
+	t.Helper()
+	if !result.Interrupted || result.Optimal {
+		t.Errorf("%s: 提前停止=%v 最优=%v, 期望提前停止且不保证最优", name, result.Interrupted, result.Optimal)
+	}
+	if len(seen) == 0 || result.HP != seen[len(seen)-1].HP || compareScores(result.Score, seen[len(seen)-1].Score) != 0 {
+		t.Errorf("%s: 结果 HP=%d, 期望停止前找到的最优解", name, result.HP)
+	}
+	if compareScores(result.Score, full.Score) > 0 {
+		t.Errorf("%s: 结果 %v 比完整搜索 %v 还好", name, result.Score, full.Score)
+	}
+}
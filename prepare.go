@@ -1,40 +1,172 @@
 package main
 
+import (
+	"container/list"
+	"sort"
+	"sync"
+)
+
+// 可达性缓存的key：已击败怪物 + 到过的楼传楼梯 + 出发区域
+// 超过64个连接点时，其余的位编码为字符串，随缓存条目一起淘汰
+type accessKey struct {
+	defeated uint64
+	wide     string // 空字符串表示没有超出64位的部分
+	stairs   string // 到过的楼传楼梯区域的编码，空字符串表示没有
+	origin   int32
+}
+
+// LRU队列中的缓存条目
+type accessEntry struct {
+	key        accessKey
+	accessible map[int]bool
+}
+
 // 预计算的映射关系
 type AccessibilityCache struct {
 	// 怪物ID -> 连接的区域列表
 	monsterToAreas map[int][]int
 	// 区域ID -> 连接到该区域的怪物列表
 	areaToMonsters map[int][]int
-	// 缓存结果: defeatedMonsters位掩码 -> 可达区域map
-	cache map[int64]map[int]bool
-	// 缓存LRU，防止内存无限增长
-	cacheOrder   []int64
+	// 区域ID -> 无需战斗即可直接到达的区域列表（楼梯等）
+	areaLinks map[int][]int
+	// 楼层传送器：可楼传的楼梯区域（按编号排序）
+	teleportStairs   []int
+	teleportStairSet map[int]bool
+	// 缓存结果: (已击败怪物, 到过的楼传楼梯, 出发区域) -> LRU队列中的条目
+	cache map[accessKey]*list.Element
+	// 缓存LRU，防止内存无限增长（队首为最久未使用的条目）
+	cacheOrder   *list.List
 	maxCacheSize int
+	// 保护缓存，并行搜索时多个协程共用同一个缓存（计算可达区域时不持有锁）
+	mu sync.Mutex
 }
 
 // 初始化缓存
-func NewAccessibilityCache(allMonsters []*GlobalMonster, maxCacheSize int) *AccessibilityCache {
+func NewAccessibilityCache(gates []*Gate, links []*AreaLink, maxCacheSize int) *AccessibilityCache {
 	cache := &AccessibilityCache{
 		monsterToAreas: make(map[int][]int),
 		areaToMonsters: make(map[int][]int),
-		cache:          make(map[int64]map[int]bool),
-		cacheOrder:     make([]int64, 0),
+		areaLinks:      make(map[int][]int),
+		cache:          make(map[accessKey]*list.Element),
+		cacheOrder:     list.New(),
 		maxCacheSize:   maxCacheSize,
 	}
 
-	// 预计算怪物-区域映射关系
-	for monsterIdx, monster := range allMonsters {
-		cache.monsterToAreas[monsterIdx] = monster.ConnectedAreas
+	// 预计算连接点-区域映射关系
+	for _, gate := range gates {
+		cache.monsterToAreas[gate.ID] = gate.Areas
 
-		for _, areaID := range monster.ConnectedAreas {
-			cache.areaToMonsters[areaID] = append(cache.areaToMonsters[areaID], monsterIdx)
+		for _, areaID := range gate.Areas {
+			cache.areaToMonsters[areaID] = append(cache.areaToMonsters[areaID], gate.ID)
 		}
 	}
 
+	for _, link := range links {
+		cache.areaLinks[link.From] = append(cache.areaLinks[link.From], link.To)
+	}
+
 	return cache
 }
 
+// 启用楼层传送器，stairsByFloor为每层可以使用楼传的楼梯区域
+func (ac *AccessibilityCache) EnableTeleporter(stairsByFloor map[int][]int) {
+	if len(stairsByFloor) == 0 {
+		return
+	}
+	ac.teleportStairSet = make(map[int]bool)
+	for _, stairAreas := range stairsByFloor {
+		for _, areaID := range stairAreas {
+			if !ac.teleportStairSet[areaID] {
+				ac.teleportStairSet[areaID] = true
+				ac.teleportStairs = append(ac.teleportStairs, areaID)
+			}
+		}
+	}
+	sort.Ints(ac.teleportStairs)
+}
+
+// 记录到过的楼传楼梯区域：返回reached加上areas中的楼梯区域（没有新增时返回reached本身，没有楼传器时为nil）
+func (ac *AccessibilityCache) reachStairs(reached *ExtendedBitSet, areas map[int]bool) *ExtendedBitSet {
+	if ac.teleportStairSet == nil {
+		return nil
+	}
+	if reached == nil {
+		reached = NewExtendedBitSet(0)
+	}
+	result := reached
+	for _, areaID := range ac.teleportStairs {
+		if areas[areaID] && !reached.IsSet(areaID) {
+			if result == reached {
+				result = reached.Copy()
+			}
+			result.Set(areaID)
+		}
+	}
+	return result
+}
+
+// 楼层传送：能到达某个楼梯区域时，之前到过的楼梯区域都变为可达，返回新增的区域
+// 只能飞到到过的楼梯处，没走到过的楼梯（例如被怪物挡住）不能直接飞过去
+func (ac *AccessibilityCache) teleportTargets(accessible map[int]bool, reached *ExtendedBitSet) []int {
+	if ac.teleportStairSet == nil || reached == nil {
+		return nil
+	}
+
+	canTeleport := false
+	for _, areaID := range ac.teleportStairs {
+		if accessible[areaID] {
+			canTeleport = true
+			break
+		}
+	}
+	if !canTeleport {
+		return nil
+	}
+
+	targets := []int{}
+	for _, areaID := range ac.teleportStairs {
+		if reached.IsSet(areaID) && !accessible[areaID] {
+			accessible[areaID] = true
+			targets = append(targets, areaID)
+		}
+	}
+	return targets
+}
+
+// 到过的楼传楼梯区域的编码，用作状态key的一部分（没有到过任何楼梯时为空）
+func stairsKey(reached *ExtendedBitSet) string {
+	if reached == nil {
+		return ""
+	}
+	low, wide := reached.Key()
+	if low == 0 && wide == "" {
+		return ""
+	}
+	return string(appendWords(nil, []uint64{low})) + wide
+}
+
+// 生成可达性缓存的key
+func newAccessKey(defeatedMonsters, reachedStairs *ExtendedBitSet, origin int) accessKey {
+	low, wide := defeatedMonsters.Key()
+	return accessKey{
+		defeated: low,
+		wide:     wide,
+		stairs:   stairsKey(reachedStairs),
+		origin:   int32(origin),
+	}
+}
+
+// 存入缓存（其他协程可能已经存入了相同的结果）
+func (ac *AccessibilityCache) store(cacheKey accessKey, accessible map[int]bool) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if _, exists := ac.cache[cacheKey]; exists {
+		return
+	}
+	ac.cache[cacheKey] = ac.cacheOrder.PushBack(&accessEntry{key: cacheKey, accessible: accessible})
+	ac.evictOldEntries()
+}
+
 // 清理LRU缓存
 func (ac *AccessibilityCache) evictOldEntries() {
 	if len(ac.cache) <= ac.maxCacheSize {
@@ -43,41 +175,38 @@ func (ac *AccessibilityCache) evictOldEntries() {
 
 	// 删除最旧的条目
 	toRemove := len(ac.cache) - ac.maxCacheSize + 1
-	for i := 0; i < toRemove && len(ac.cacheOrder) > 0; i++ {
-		oldKey := ac.cacheOrder[0]
-		delete(ac.cache, oldKey)
-		ac.cacheOrder = ac.cacheOrder[1:]
+	for i := 0; i < toRemove && ac.cacheOrder.Len() > 0; i++ {
+		oldest := ac.cacheOrder.Front()
+		delete(ac.cache, oldest.Value.(*accessEntry).key)
+		ac.cacheOrder.Remove(oldest)
 	}
 }
 
-// 获取可达区域（带缓存）
-func (ac *AccessibilityCache) GetAccessibleAreas(defeatedMonsters int64, startArea int) map[int]bool {
+// 获取可达区域（带缓存），reachedStairs为到过的楼传楼梯区域
+func (ac *AccessibilityCache) GetAccessibleAreas(defeatedMonsters, reachedStairs *ExtendedBitSet, startArea int) map[int]bool {
+	cacheKey := newAccessKey(defeatedMonsters, reachedStairs, startArea)
+	ac.mu.Lock()
+
 	// 检查缓存
-	if cached, exists := ac.cache[defeatedMonsters]; exists {
+	if element, exists := ac.cache[cacheKey]; exists {
 		// 移动到LRU队列末尾
-		for i, key := range ac.cacheOrder {
-			if key == defeatedMonsters {
-				ac.cacheOrder = append(ac.cacheOrder[:i], ac.cacheOrder[i+1:]...)
-				break
-			}
-		}
-		ac.cacheOrder = append(ac.cacheOrder, defeatedMonsters)
-		return cached
+		ac.cacheOrder.MoveToBack(element)
+		ac.mu.Unlock()
+		return element.Value.(*accessEntry).accessible
 	}
+	ac.mu.Unlock()
 
 	// 计算可达区域
-	accessible := ac.calculateAccessibleAreas(defeatedMonsters, startArea)
+	accessible := ac.calculateAccessibleAreas(defeatedMonsters, reachedStairs, startArea)
 
 	// 存入缓存
-	ac.cache[defeatedMonsters] = accessible
-	ac.cacheOrder = append(ac.cacheOrder, defeatedMonsters)
-	ac.evictOldEntries()
+	ac.store(cacheKey, accessible)
 
 	return accessible
 }
 
 // 实际计算可达区域（优化版）
-func (ac *AccessibilityCache) calculateAccessibleAreas(defeatedMonsters int64, startArea int) map[int]bool {
+func (ac *AccessibilityCache) calculateAccessibleAreas(defeatedMonsters, reachedStairs *ExtendedBitSet, startArea int) map[int]bool {
 	accessible := make(map[int]bool)
 	accessible[startArea] = true
 	queue := []int{startArea}
@@ -86,15 +215,19 @@ func (ac *AccessibilityCache) calculateAccessibleAreas(defeatedMonsters int64, s
 		currentArea := queue[0]
 		queue = queue[1:]
 
+		// 沿区域之间的直接连接（楼梯等）扩展
+		for _, linkedAreaID := range ac.areaLinks[currentArea] {
+			if !accessible[linkedAreaID] {
+				accessible[linkedAreaID] = true
+				queue = append(queue, linkedAreaID)
+			}
+		}
+
 		// 检查从当前区域能通过哪些已击败的怪物到达新区域
 		if monsters, exists := ac.areaToMonsters[currentArea]; exists {
 			for _, monsterIdx := range monsters {
-				if hasBit(defeatedMonsters, monsterIdx) { // 怪物已被击败
-					// 该怪物连接的所有区域和怪物自身区域都变为可访问
-					// 添加怪物自身区域作为可达区域
-					monsterAreaID := monsterIdx + len(ac.areaToMonsters)
-					accessible[monsterAreaID] = true
-					queue = append(queue, monsterAreaID)
+				if defeatedMonsters.IsSet(monsterIdx) { // 怪物已被击败
+					// 该怪物连接的所有区域都变为可访问
 					for _, connectedAreaID := range ac.monsterToAreas[monsterIdx] {
 						if !accessible[connectedAreaID] {
 							accessible[connectedAreaID] = true
@@ -104,23 +237,34 @@ func (ac *AccessibilityCache) calculateAccessibleAreas(defeatedMonsters int64, s
 				}
 			}
 		}
+
+		// 队列耗尽后尝试楼层传送
+		if len(queue) == 0 {
+			queue = ac.teleportTargets(accessible, reachedStairs)
+		}
 	}
 
 	return accessible
 }
 
 // 增量更新可达区域（当击败新怪物时）
+// 只适用于没有单向连接的图：否则从新位置出发的可达区域不一定包含原来的可达区域
 func (ac *AccessibilityCache) GetAccessibleAreasIncremental(
-	baseDefeatedMonsters int64,
+	baseDefeatedMonsters *ExtendedBitSet,
+	reachedStairs *ExtendedBitSet,
 	newlyDefeatedMonster int,
 	startArea int,
 	baseAccessible map[int]bool) map[int]bool {
 
-	newDefeatedMonsters := setBit(baseDefeatedMonsters, newlyDefeatedMonster)
+	newDefeatedMonsters := setBitExt(baseDefeatedMonsters, newlyDefeatedMonster)
+	cacheKey := newAccessKey(newDefeatedMonsters, reachedStairs, startArea)
+	ac.mu.Lock()
 
 	// 检查缓存
-	if cached, exists := ac.cache[newDefeatedMonsters]; exists {
-		return cached
+	element, exists := ac.cache[cacheKey]
+	ac.mu.Unlock()
+	if exists {
+		return element.Value.(*accessEntry).accessible
 	}
 
 	// 增量计算：基于已有的可达区域，只处理新击败怪物的影响
@@ -136,11 +280,7 @@ func (ac *AccessibilityCache) GetAccessibleAreasIncremental(
 	if areas, exists := ac.monsterToAreas[newlyDefeatedMonster]; exists {
 		for _, areaID := range areas {
 			if newAccessible[areaID] {
-				// 如果怪物连接的区域已经可达，则怪物连接的所有区域和怪物自身区域都变为可达
-				// 添加怪物自身区域作为可达区域
-				monsterAreaID := newlyDefeatedMonster + len(ac.areaToMonsters)
-				newAccessible[monsterAreaID] = true
-				queue = append(queue, monsterAreaID)
+				// 如果怪物连接的区域已经可达，则怪物连接的所有区域都变为可达
 				for _, connectedAreaID := range areas {
 					if !newAccessible[connectedAreaID] {
 						newAccessible[connectedAreaID] = true
@@ -157,13 +297,16 @@ func (ac *AccessibilityCache) GetAccessibleAreasIncremental(
 		currentArea := queue[0]
 		queue = queue[1:]
 
+		for _, linkedAreaID := range ac.areaLinks[currentArea] {
+			if !newAccessible[linkedAreaID] {
+				newAccessible[linkedAreaID] = true
+				queue = append(queue, linkedAreaID)
+			}
+		}
+
 		if monsters, exists := ac.areaToMonsters[currentArea]; exists {
 			for _, monsterIdx := range monsters {
-				if hasBit(newDefeatedMonsters, monsterIdx) {
-					// 添加怪物自身区域作为可达区域
-					monsterAreaID := monsterIdx + len(ac.areaToMonsters)
-					newAccessible[monsterAreaID] = true
-					queue = append(queue, monsterAreaID)
+				if newDefeatedMonsters.IsSet(monsterIdx) {
 					for _, connectedAreaID := range ac.monsterToAreas[monsterIdx] {
 						if !newAccessible[connectedAreaID] {
 							newAccessible[connectedAreaID] = true
@@ -173,12 +316,26 @@ func (ac *AccessibilityCache) GetAccessibleAreasIncremental(
 				}
 			}
 		}
+
+		if len(queue) == 0 {
+			queue = ac.teleportTargets(newAccessible, reachedStairs)
+		}
 	}
 
 	// 存入缓存
-	ac.cache[newDefeatedMonsters] = newAccessible
-	ac.cacheOrder = append(ac.cacheOrder, newDefeatedMonsters)
-	ac.evictOldEntries()
+	ac.store(cacheKey, newAccessible)
 
 	return newAccessible
 }
+
+// 获取从出发区域可达、并且还能走回出发区域的区域
+// 存在单向连接时，只有这些区域的宝物能顺路拿到而不改变勇士所在的位置
+func (ac *AccessibilityCache) GetMutualAreas(defeatedMonsters, reachedStairs *ExtendedBitSet, startArea int) map[int]bool {
+	mutual := make(map[int]bool)
+	for areaID := range ac.GetAccessibleAreas(defeatedMonsters, reachedStairs, startArea) {
+		if areaID == startArea || ac.GetAccessibleAreas(defeatedMonsters, reachedStairs, areaID)[startArea] {
+			mutual[areaID] = true
+		}
+	}
+	return mutual
+}
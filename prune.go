@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// 剪枝规则类型
+const (
+	pruneFights      = "fights"      // 战斗Fights次后攻防和的提升不超过Value时剪枝
+	pruneMoney       = "money"       // 金币超过Value时剪枝
+	pruneConsecutive = "consecutive" // 连续战斗Fights次后攻击或防御仍比要求低Value以上时剪枝（以Boss为目标时要求见bossTarget）
+	pruneGoal        = "goal"        // 到达终点后不再继续扩展
+)
+
+// 启发式剪枝规则，会剪掉可能是最优的路线
+type PruneRule struct {
+	Kind     string
+	Disabled bool // 单独关闭该规则
+	Fights   int8
+	Value    int
+}
+
+// 剪枝配置：每关可以使用不同的规则
+type PruneConfig struct {
+	Exact bool // 精确模式：关闭所有启发式剪枝，结果保证最优
+	Rules []*PruneRule
+}
+
+// 从JSON文件加载剪枝配置
+func LoadPruneConfig(path string) (*PruneConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config PruneConfig
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, err
+	}
+	for _, rule := range config.Rules {
+		switch rule.Kind {
+		case pruneFights, pruneMoney, pruneConsecutive, pruneGoal:
+		default:
+			return nil, fmt.Errorf("未知的剪枝规则: %s", rule.Kind)
+		}
+	}
+	return &config, nil
+}
+
+// 规则是否生效
+func (c *PruneConfig) enabled(kind string) bool {
+	if c == nil || c.Exact {
+		return false
+	}
+	for _, rule := range c.Rules {
+		if rule.Kind == kind && !rule.Disabled {
+			return true
+		}
+	}
+	return false
+}
+
+// 出发时计入装备加成的攻防和，与State.TotalATK、TotalDEF一致
+func startAtkDef(hero *HeroItem) int {
+	start := &State{ATK: hero.ATK, DEF: hero.DEF, Equipped: hero.Equipped}
+	return int(start.TotalATK()) + int(start.TotalDEF())
+}
+
+// 剪枝检查：baseAtkDef为出发时的攻防和（见startAtkDef）
+func (c *PruneConfig) shouldPrune(state *State, requiredATK, requiredDEF int8, baseAtkDef int) bool {
+	if c == nil || c.Exact {
+		return false
+	}
+	atkDefImprovement := int(state.TotalATK()) + int(state.TotalDEF()) - baseAtkDef
+
+	for _, rule := range c.Rules {
+		if rule.Disabled {
+			continue
+		}
+		switch rule.Kind {
+		case pruneFights:
+			if state.FightsSinceStart >= rule.Fights && atkDefImprovement <= rule.Value {
+				return true
+			}
+		case pruneMoney:
+			if int(state.Money) > rule.Value {
+				return true
+			}
+		case pruneConsecutive:
+			// 注意：只计算非零伤害的怪物
+			if state.ConsecutiveFights >= rule.Fights &&
+				(int(state.TotalATK()) < int(requiredATK)-rule.Value || int(state.TotalDEF()) < int(requiredDEF)-rule.Value) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+// 攻防和的提升用int计算，高攻防不会溢出成负数；出发时的装备不算提升
+func TestShouldPruneAtkDefImprovement(t *testing.T) {
+	config := &PruneConfig{Rules: []*PruneRule{{Kind: pruneFights, Fights: 4, Value: 0}}}
+	strong := &State{ATK: 100, DEF: 100, FightsSinceStart: 4}
+	if config.shouldPrune(strong, 0, 0, startAtkDef(&HeroItem{ATK: 10, DEF: 10})) {
+		t.Error("攻防和提升180的状态不应被剪枝")
+	}
+
+	sword := &Equipment{Name: "银剑", Slot: slotWeapon, ATK: 4}
+	hero := &HeroItem{ATK: 10, DEF: 6, Equipped: [equipSlotCount]*Equipment{slotWeapon: sword}}
+	if base := startAtkDef(hero); base != 20 {
+		t.Fatalf("出发时的攻防和 = %d, 期望计入装备为20", base)
+	}
+	unchanged := &State{ATK: 10, DEF: 6, Equipped: hero.Equipped, FightsSinceStart: 4}
+	if !config.shouldPrune(unchanged, 0, 0, startAtkDef(hero)) {
+		t.Error("只有出发时的装备、攻防没有提升的状态应被剪枝")
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 图化简报告
+type SimplifyReport struct {
+	RemovedGates []string   // 删除的无用连接点
+	Corridors    [][]string // 合并成宏连接点的走廊怪物链（按顺序）
+}
+
+// 打印化简报告
+func (r *SimplifyReport) Print() {
+	fmt.Printf("图化简: 删除%d个无用连接点, 合并%d条走廊\n", len(r.RemovedGates), len(r.Corridors))
+	for _, key := range r.RemovedGates {
+		fmt.Printf("  删除 %s\n", key)
+	}
+	for _, chain := range r.Corridors {
+		fmt.Printf("  合并 %s\n", strings.Join(chain, " -> "))
+	}
+	if len(r.Corridors) > 0 {
+		fmt.Println("  （合并的走廊只能整条打完，不能只为金币打其中一部分）")
+	}
+	if len(r.RemovedGates) == 0 && len(r.Corridors) == 0 {
+		fmt.Println("  只删除没有金币、只通向空死胡同的怪物和门，只合并两侧各只连一个区域、中间是空区域的普通怪物；" +
+			"怪物都在路口或旁边有宝物时不会化简")
+	}
+}
+
+// 是否为可以化简的普通怪物（门、NPC、物品、动态格子、有特殊能力的怪物都不化简）
+func isPlainMonster(conn *Gate) bool {
+	if conn.NPC != nil || conn.Item != nil || conn.Dynamic != nil {
+		return false
+	}
+	if len(conn.Chain) > 0 {
+		return true
+	}
+	return conn.Monster != nil && conn.Monster.Special == 0 &&
+		conn.MonsterID != YellowDoorID && conn.MonsterID != BlueDoorID
+}
+
+// 图化简器
+type graphSimplifier struct {
+	graph     *Graph          // 化简过程中的图（原图的副本），每次删除或合并连接点后重新编号
+	protected map[[3]int]bool // 被NPC或触发器引用的位置（楼层, x, y）
+	pinned    map[int]bool    // 不能当作空死胡同的区域
+	report    *SimplifyReport
+}
+
+// 化简图：删除永远不会有用的怪物，把走廊里的怪物链合并成一个宏连接点
+// 合并后走廊只能整条打完，只为金币打走廊中的一部分怪物的路线会被忽略
+// keep中的位置（楼层, x, y）及其所在区域保持不变，返回新的图，原图保持不变
+func (g *Graph) Simplify(keep ...[3]int) (*Graph, *SimplifyReport) {
+	simplified := *g
+	s := &graphSimplifier{
+		graph:     &simplified,
+		protected: make(map[[3]int]bool),
+		pinned:    make(map[int]bool),
+		report:    &SimplifyReport{RemovedGates: []string{}, Corridors: [][]string{}},
+	}
+	s.collectProtected()
+	for _, pos := range keep {
+		s.protected[pos] = true
+		if areaID := g.AreaAt(pos[0], [2]int{pos[1], pos[2]}); areaID >= 0 {
+			s.pinned[areaID] = true
+		}
+	}
+
+	for s.removeUselessGate() || s.mergeCorridor() {
+	}
+
+	for _, gate := range simplified.Gates {
+		if len(gate.Chain) > 0 {
+			s.report.Corridors = append(s.report.Corridors, memberNames(gate))
+		}
+	}
+	return &simplified, s.report
+}
+
+// 替换化简中的图的连接点并重新编号（indexGates会复制连接点和区域，不影响原图）
+func (s *graphSimplifier) setGates(gates []*Gate) {
+	s.graph.Gates = gates
+	s.graph.indexGates()
+}
+
+// 收集不能化简的位置和区域
+func (s *graphSimplifier) collectProtected() {
+	for _, conn := range s.graph.Gates {
+		if conn.NPC == nil {
+			continue
+		}
+		for _, pos := range conn.NPC.RequiredDefeated {
+			s.protected[[3]int{conn.Floor, pos[0], pos[1]}] = true
+		}
+	}
+	for _, trigger := range s.graph.Triggers {
+		for _, positions := range [][][2]int{trigger.Guards, trigger.Tiles, trigger.Open, trigger.Close} {
+			for _, pos := range positions {
+				s.protected[[3]int{trigger.Floor, pos[0], pos[1]}] = true
+			}
+		}
+	}
+
+	s.pinned[s.graph.StartArea] = true
+	s.pinned[s.graph.EndArea] = true
+	for _, link := range s.graph.Links {
+		s.pinned[link.From] = true
+		s.pinned[link.To] = true
+	}
+	for _, area := range s.graph.Areas {
+		if len(area.Treasures) > 0 {
+			s.pinned[area.ID] = true
+		}
+	}
+}
+
+// 连接点是否可以合并到走廊怪物链中
+// 搜索时为怪物链中的每场战斗分别选择技能，整条链打完后获得链中所有怪物的金币
+func (s *graphSimplifier) canSimplify(conn *Gate) bool {
+	return isPlainMonster(conn) && !s.protected[[3]int{conn.Floor, conn.Pos[0], conn.Pos[1]}]
+}
+
+// 连接点是否可以在通向空死胡同时删除（没有金币的怪物和门）
+func (s *graphSimplifier) canRemove(conn *Gate) bool {
+	if conn.NPC != nil || conn.Item != nil || conn.Dynamic != nil {
+		return false
+	}
+	if len(conn.Chain) == 0 && conn.Monster == nil {
+		return false
+	}
+	return chainMoney(conn) == 0 && !s.protected[[3]int{conn.Floor, conn.Pos[0], conn.Pos[1]}]
+}
+
+// 删除一个没有金币、只通向空死胡同的怪物或门，返回是否有变化
+func (s *graphSimplifier) removeUselessGate() bool {
+	for _, conn := range s.graph.Gates {
+		if !s.canRemove(conn) {
+			continue
+		}
+		// 除了至多一个来路区域之外，其余连接的区域都必须是只连着该怪物的空区域
+		openAreas := 0
+		for _, area := range s.graph.AreasOf(conn.ID) {
+			if s.pinned[area.ID] || len(s.graph.GatesOf(area.ID)) > 1 {
+				openAreas++
+			}
+		}
+		if openAreas > 1 {
+			continue
+		}
+		s.report.RemovedGates = append(s.report.RemovedGates, memberNames(conn)...)
+		gates := make([]*Gate, 0, len(s.graph.Gates)-1)
+		gates = append(gates, s.graph.Gates[:conn.ID]...)
+		s.setGates(append(gates, s.graph.Gates[conn.ID+1:]...))
+		return true
+	}
+	return false
+}
+
+// 把中间是空区域的两个怪物合并成一个宏连接点，返回是否有变化
+func (s *graphSimplifier) mergeCorridor() bool {
+	for _, area := range s.graph.Areas {
+		if s.pinned[area.ID] {
+			continue
+		}
+		neighbors := s.graph.GatesOf(area.ID)
+		if len(neighbors) != 2 {
+			continue
+		}
+		first, second := neighbors[0], neighbors[1]
+		if !s.canSimplify(first) || !s.canSimplify(second) ||
+			len(first.Areas) != 2 || len(second.Areas) != 2 {
+			continue
+		}
+		from, to := otherArea(first, area.ID), otherArea(second, area.ID)
+		if from == to {
+			continue
+		}
+
+		merged := &Gate{
+			MonsterID: first.MonsterID,
+			Floor:     first.Floor,
+			Pos:       first.Pos,
+			Areas:     []int{from, to},
+			Chain:     append(orientedChain(first, from), orientedChain(second, area.ID)...),
+		}
+		gates := make([]*Gate, 0, len(s.graph.Gates)-1)
+		for _, gate := range s.graph.Gates {
+			if gate.ID != first.ID && gate.ID != second.ID {
+				gates = append(gates, gate)
+			}
+		}
+		s.setGates(append(gates, merged))
+		return true
+	}
+	return false
+}
+
+// 连接点合并前的名称（按怪物链的顺序）
+func memberNames(gate *Gate) []string {
+	names := []string{}
+	for _, member := range chainOf(gate) {
+		names = append(names, member.Name())
+	}
+	return names
+}
+
+// 宏连接点展开后的单个怪物
+func chainOf(conn *Gate) []*Gate {
+	if len(conn.Chain) > 0 {
+		return conn.Chain
+	}
+	return []*Gate{conn}
+}
+
+// 已击败的怪物数，走廊怪物链按其中的怪物数计，结果与化简前的图相同
+func defeatedCount(defeated *ExtendedBitSet, gates []*Gate) int {
+	count := 0
+	for _, gate := range gates {
+		if defeated.IsSet(gate.ID) {
+			count += len(chainOf(gate))
+		}
+	}
+	return count
+}
+
+// 从指定区域一侧进入时依次遇到的怪物
+func orientedChain(conn *Gate, fromArea int) []*Gate {
+	if conn.Areas[0] != fromArea {
+		return reversedChain(chainOf(conn))
+	}
+	return append([]*Gate{}, chainOf(conn)...)
+}
+
+// 反向的怪物链
+func reversedChain(chain []*Gate) []*Gate {
+	reversed := make([]*Gate, len(chain))
+	for i, member := range chain {
+		reversed[len(chain)-1-i] = member
+	}
+	return reversed
+}
+
+// 连接点（或整条怪物链）提供的金币
+func chainMoney(conn *Gate) int {
+	money := 0
+	for _, member := range chainOf(conn) {
+		money += int(member.Monster.Money)
+	}
+	return money
+}
+
+// 连接点另一侧的区域
+func otherArea(conn *Gate, areaID int) int {
+	if conn.Areas[0] == areaID {
+		return conn.Areas[1]
+	}
+	return conn.Areas[0]
+}
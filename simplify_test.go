@@ -0,0 +1,103 @@
+package main
+
+import "testing"
+
+// 默认配置（有技能）下走廊里的怪物会合并，合并后每场战斗仍可以选择技能，中毒时每场战斗都扣血
+func TestSimplifyCorridorWithSkills(t *testing.T) {
+	initDamageCache()
+	if len(skillList) == 0 {
+		t.Fatal("默认配置应该有技能")
+	}
+	m := [][]int{
+		{1, 0, 1},
+		{1, 214, 1},
+		{1, 0, 1},
+		{1, 214, 1},
+		{1, 0, 1},
+		{1, 214, 1},
+		{1, 0, 1},
+		{1, 215, 1}, // 毒蝙蝠有特殊能力，不合并
+		{1, 0, 1},
+	}
+	graph := convertForTest(t, m, [2]int{8, 1}, [2]int{0, 1})
+	simplified, report := graph.Simplify()
+	if len(report.Corridors) != 1 || len(report.Corridors[0]) != 3 {
+		t.Fatalf("合并的走廊 = %v, 期望一条3个怪物的走廊", report.Corridors)
+	}
+
+	search := func(g *Graph) SearchResult {
+		return findOptimalPathWithOptions(g, &HeroItem{HP: 1000, MP: 20, ATK: 15, DEF: 6, AreaID: g.StartArea},
+			&HeroItem{AreaID: g.EndArea}, &SearchOptions{Prune: &PruneConfig{Exact: true}})
+	}
+	// 中毒后合并的走廊每场战斗都要扣血，技能也要按每场战斗选择，否则结果与化简前不同
+	original, merged := search(graph), search(simplified)
+	if original.HP <= 0 || merged.HP != original.HP || merged.MP != original.MP {
+		t.Fatalf("化简前 HP=%d MP=%d, 化简后 HP=%d MP=%d", original.HP, original.MP, merged.HP, merged.MP)
+	}
+	skills := 0
+	for _, action := range merged.Path {
+		if action.Type == actionFight && action.Skill >= 0 {
+			skills++
+		}
+	}
+	if skills == 0 || merged.MP != 0 {
+		t.Errorf("化简后的路线使用了%d次技能, 剩余MP=%d, 期望用完MP", skills, merged.MP)
+	}
+}
+
+// 合并的走廊按其中的怪物数计入击败数，化简前后的击败数、defeated目标和指标都相同
+func TestSimplifyKeepsDefeatedCount(t *testing.T) {
+	initDamageCache()
+	m := [][]int{
+		{1, 0, 1},
+		{1, 214, 1},
+		{1, 0, 1},
+		{1, 214, 1},
+		{1, 0, 1},
+		{1, 214, 1},
+		{1, 0, 1},
+	}
+	graph := convertForTest(t, m, [2]int{6, 1}, [2]int{0, 1})
+	simplified, report := graph.Simplify()
+	if len(report.Corridors) != 1 {
+		t.Fatalf("合并的走廊 = %v, 期望一条", report.Corridors)
+	}
+	for _, g := range []*Graph{graph, simplified} {
+		result := findOptimalPathWithOptions(g, &HeroItem{HP: 3000, ATK: 15, DEF: 6, AreaID: g.StartArea}, &HeroItem{AreaID: g.EndArea},
+			&SearchOptions{
+				Prune:     &PruneConfig{Exact: true},
+				Goal:      StatGoal(metricDefeated, 3),
+				Objective: &Objective{Terms: []ObjectiveTerm{{Metric: metricDefeated, Weight: 1}}},
+			})
+		if result.DefeatedCount != 3 || len(result.Score) != 1 || result.Score[0] != 3 {
+			t.Errorf("%d个连接点的图: 击败%d个 得分%v, 期望击败3个", len(g.Gates), result.DefeatedCount, result.Score)
+		}
+	}
+}
+
+// 有金币的怪物也会合并，整条走廊打完后获得所有怪物的金币
+func TestSimplifyCorridorKeepsMoney(t *testing.T) {
+	initDamageCache()
+	m := [][]int{
+		{1, 0, 1},
+		{1, 213, 1},
+		{1, 0, 1},
+		{1, 213, 1},
+		{1, 0, 1},
+		{1, 213, 1},
+		{1, 0, 1},
+	}
+	graph := convertForTest(t, m, [2]int{6, 1}, [2]int{0, 1})
+	simplified, report := graph.Simplify()
+	if len(report.Corridors) != 1 || len(report.Corridors[0]) != 3 {
+		t.Fatalf("合并的走廊 = %v, 期望一条3个怪物的走廊", report.Corridors)
+	}
+	want := 3 * int32(monsterMap[213].Money)
+	for _, g := range []*Graph{graph, simplified} {
+		result := findOptimalPathWithOptions(g, &HeroItem{HP: 1000, ATK: 15, DEF: 6, AreaID: g.StartArea},
+			&HeroItem{AreaID: g.EndArea}, &SearchOptions{Prune: &PruneConfig{Exact: true}})
+		if result.Money != want {
+			t.Errorf("%d个连接点的图: 金币=%d, 期望%d", len(g.Gates), result.Money, want)
+		}
+	}
+}
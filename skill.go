@@ -0,0 +1,29 @@
+package main
+
+// 勇士的主动技能，战斗前选择是否使用，消耗MP
+type Skill struct {
+	Name          string
+	MPCost        int16
+	ATKMultiplier int8 // 战斗中攻击力倍数（二倍斩为2），0表示不改变攻击力
+	DEFBonus      int8 // 战斗中额外增加的防御力（护盾）
+}
+
+// 使用技能后的战斗攻防：用int计算避免int8溢出，超出int8范围时取边界值
+// 超出伤害缓存范围的攻防由getDamage直接计算
+func (sk *Skill) battleStats(atk, def int8) (int8, int8) {
+	battleATK, battleDEF := int(atk), int(def)+int(sk.DEFBonus)
+	if sk.ATKMultiplier > 0 {
+		battleATK *= int(sk.ATKMultiplier)
+	}
+	return clampInt8(battleATK), clampInt8(battleDEF)
+}
+
+// 应用宝物中的魔法值
+func applyMana(allTreasures []*GlobalTreasure, mp int16, treasureIndices []int) int16 {
+	for _, idx := range treasureIndices {
+		if allTreasures[idx].Type == treasureMP {
+			mp = clampInt16(int(mp) + int(allTreasures[idx].Value))
+		}
+	}
+	return mp
+}
@@ -0,0 +1,78 @@
+package main
+
+// 结果最好的K条不同路线：击败的连接点或购买攻防的次数不同才算不同路线
+// 同一条路线只保留结果最好的状态
+type topRoutes struct {
+	k         int
+	objective *Objective
+	gates     []*Gate
+	states    []*State   // 按结果从好到差排列
+	keys      []StateKey // 与states一一对应的路线标识
+	scores    [][]int    // 与states一一对应的目标得分
+}
+
+func newTopRoutes(k int, objective *Objective, gates []*Gate) *topRoutes {
+	if k < 1 {
+		k = 1
+	}
+	return &topRoutes{k: k, objective: objective, gates: gates}
+}
+
+// 路线标识：击败的连接点和购买次数
+func routeKey(state *State) StateKey {
+	return encodeState(state.DefeatedMonsters, 0, 0, 0, state.ATKBuys, state.DEFBuys, 0, 0)
+}
+
+// 加入一个到达终点的状态，返回它是否被保留
+func (t *topRoutes) offer(state *State) bool {
+	key := routeKey(state)
+	score := t.objective.score(state, t.gates)
+	for i, existingKey := range t.keys {
+		if existingKey != key {
+			continue
+		}
+		if compareScores(score, t.scores[i]) <= 0 {
+			return false
+		}
+		t.remove(i)
+		break
+	}
+	if len(t.states) == t.k && compareScores(score, t.scores[t.k-1]) <= 0 {
+		return false
+	}
+
+	pos := len(t.states)
+	for pos > 0 && compareScores(score, t.scores[pos-1]) > 0 {
+		pos--
+	}
+	t.states = append(t.states[:pos], append([]*State{state}, t.states[pos:]...)...)
+	t.keys = append(t.keys[:pos], append([]StateKey{key}, t.keys[pos:]...)...)
+	t.scores = append(t.scores[:pos], append([][]int{score}, t.scores[pos:]...)...)
+	if len(t.states) > t.k {
+		t.states, t.keys, t.scores = t.states[:t.k], t.keys[:t.k], t.scores[:t.k]
+	}
+	return true
+}
+
+func (t *topRoutes) remove(i int) {
+	t.states = append(t.states[:i], t.states[i+1:]...)
+	t.keys = append(t.keys[:i], t.keys[i+1:]...)
+	t.scores = append(t.scores[:i], t.scores[i+1:]...)
+}
+
+// 最好的状态，还没有路线时返回nil
+func (t *topRoutes) best() *State {
+	if len(t.states) == 0 {
+		return nil
+	}
+	return t.states[0]
+}
+
+// 分支定界的门槛：目标的首要指标不超过血量且已有K条路线时返回第K好的路线在该指标上的值，
+// 血量上限低于它的状态不可能进入前K
+func (t *topRoutes) threshold() (int, bool) {
+	if len(t.states) < t.k || !t.objective.hpFirst() {
+		return 0, false
+	}
+	return t.objective.Terms[0].value(t.states[t.k-1], t.gates), true
+}
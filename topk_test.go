@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// 击败给定连接点、血量为hp的状态
+func routeState(hp int16, defeated ...int) *State {
+	bits := NewExtendedBitSet(8)
+	for _, id := range defeated {
+		bits.Set(id)
+	}
+	return &State{HP: hp, DefeatedMonsters: bits}
+}
+
+func TestTopRoutesKeepsDistinctRoutes(t *testing.T) {
+	top := newTopRoutes(2, defaultObjective, nil)
+	if !top.offer(routeState(100, 1)) || !top.offer(routeState(90, 2)) {
+		t.Fatal("前两条不同的路线应该被保留")
+	}
+	// 同一条路线只保留结果更好的状态
+	if top.offer(routeState(80, 1)) {
+		t.Error("同一路线更差的状态不应被保留")
+	}
+	if !top.offer(routeState(120, 2)) {
+		t.Error("同一路线更好的状态应该替换原来的")
+	}
+	// 已满K条时，比第K条差的路线不保留
+	if top.offer(routeState(50, 3)) {
+		t.Error("比第K条差的路线不应被保留")
+	}
+	if len(top.states) != 2 || top.states[0].HP != 120 || top.states[1].HP != 100 {
+		t.Errorf("路线 = %v, 期望血量依次为120、100", top.states)
+	}
+	if threshold, full := top.threshold(); !full || threshold != 100 {
+		t.Errorf("门槛 = %d %v, 期望100", threshold, full)
+	}
+}
+
+// 路线标识：击败的连接点位置和购买次数
+func pathRoute(path []Action) string {
+	positions := []string{}
+	buys := [2]int{}
+	for _, action := range path {
+		switch action.Type {
+		case actionBuyATK:
+			buys[0]++
+		case actionBuyDEF:
+			buys[1]++
+		case actionFight, actionNPC, actionPickItem, actionTrigger:
+			positions = append(positions, fmt.Sprint(action.Floor, action.Pos))
+		}
+	}
+	sort.Strings(positions)
+	return fmt.Sprint(positions, buys)
+}
+
+func TestSearchTopKRoutes(t *testing.T) {
+	initDamageCache()
+	for _, c := range sampleCases {
+		graph := convertForTest(t, c.m, c.start, c.end)
+		best := searchSample(t, graph, c, &SearchOptions{Prune: &PruneConfig{Exact: true}})
+		result := searchSample(t, graph, c, &SearchOptions{Prune: &PruneConfig{Exact: true}, TopK: 3})
+		if len(result.Routes) == 0 || compareScores(result.Routes[0].Score, best.Score) != 0 {
+			t.Errorf("%s: 第一条路线应为最优解 %v", c.name, best.Score)
+			continue
+		}
+		seen := make(map[string]bool)
+		for i, route := range result.Routes {
+			if i > 0 && compareScores(route.Score, result.Routes[i-1].Score) > 0 {
+				t.Errorf("%s: 第%d条路线比前一条好", c.name, i+1)
+			}
+			key := pathRoute(route.Path)
+			if seen[key] {
+				t.Errorf("%s: 第%d条路线与前面的路线相同", c.name, i+1)
+			}
+			seen[key] = true
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import "fmt"
+
+// 楼层定义
+type Floor struct {
+	GameMap  [][]int
+	Portals  map[[2]int][2]int // 本层传送门到目标格子的映射
+	Triggers []*Trigger        // 本层的事件触发器
+}
+
+// 多层塔定义：第i层的上楼梯与第i+1层的下楼梯相连
+type Tower struct {
+	Floors     []*Floor
+	StartFloor int
+	Start      [2]int
+	EndFloor   int
+	End        [2]int
+	Teleporter *Teleporter // 楼层传送器（nil表示没有）
+}
+
+// 楼层传送器：站在楼梯所在区域时，可以直接飞到任一到过的楼梯处
+type Teleporter struct {
+	DisabledFloors []int // 禁止楼传的楼层：既不能从这些楼层飞出，也不能飞到这些楼层
+}
+
+// 检查楼层是否允许楼传
+func (t *Teleporter) allows(floor int) bool {
+	for _, disabled := range t.DisabledFloors {
+		if disabled == floor {
+			return false
+		}
+	}
+	return true
+}
+
+// 多层塔转图转换器：逐层转换后合并成一张图，楼梯作为区域之间的直接连接
+type TowerToGraphConverter struct {
+	tower       *Tower
+	treasureMap map[int]*Treasure
+	monsterMap  map[int]*Monster
+	npcMap      map[int]*NPC
+}
+
+// 创建新的多层塔转换器
+func NewTowerToGraphConverter(tower *Tower, treasureMap map[int]*Treasure, monsterMap map[int]*Monster, npcMap map[int]*NPC) *TowerToGraphConverter {
+	return &TowerToGraphConverter{
+		tower:       tower,
+		treasureMap: treasureMap,
+		monsterMap:  monsterMap,
+		npcMap:      npcMap,
+	}
+}
+
+// 转换整座塔，区域ID在所有楼层间统一编号
+func (tc *TowerToGraphConverter) Convert() (*Graph, error) {
+	graph := &Graph{
+		Areas:          []*Area{},
+		StartArea:      -1,
+		EndArea:        -1,
+		Gates:          []*Gate{},
+		BreakPoints:    []*BreakPoint{},
+		Links:          []*AreaLink{},
+		FloorAreaMaps:  make([][][]int, len(tc.tower.Floors)),
+		StairAreas:     make([][]int, len(tc.tower.Floors)),
+		Teleporter:     tc.tower.Teleporter,
+		Triggers:       []*Trigger{},
+		centerFlyCache: make(map[int]*CenterFlyResult),
+	}
+
+	// 每层楼梯所在的区域
+	upStairs := make([][]int, len(tc.tower.Floors))
+	downStairs := make([][]int, len(tc.tower.Floors))
+
+	for floorIdx, floor := range tc.tower.Floors {
+		// 起点和终点只在对应楼层生效
+		start, end := [2]int{-1, -1}, [2]int{-1, -1}
+		if floorIdx == tc.tower.StartFloor {
+			start = tc.tower.Start
+		}
+		if floorIdx == tc.tower.EndFloor {
+			end = tc.tower.End
+		}
+
+		converter := NewMapToGraphConverter(floor.GameMap, tc.treasureMap, tc.monsterMap, tc.npcMap, start, end)
+		converter.SetPortals(floor.Portals)
+		// 复制触发器再设置楼层，不修改调用者的楼层定义
+		triggers := make([]*Trigger, len(floor.Triggers))
+		for i, trigger := range floor.Triggers {
+			copied := *trigger
+			copied.Floor = floorIdx
+			triggers[i] = &copied
+		}
+		converter.SetTriggers(triggers)
+		floorGraph, err := converter.Convert()
+		if err != nil {
+			return nil, fmt.Errorf("第%d层: %w", floorIdx, err)
+		}
+		offset := len(graph.Areas)
+
+		for _, area := range floorGraph.Areas {
+			area.ID += offset
+			area.Floor = floorIdx
+			graph.Areas = append(graph.Areas, area)
+		}
+
+		for _, gate := range floorGraph.Gates {
+			gate.Floor = floorIdx
+			if gate.Dynamic != nil {
+				// 触发器编号在所有楼层间统一编号，复制后再改，不修改转换器中的动态格子
+				dynamic := *gate.Dynamic
+				dynamic.Trigger += len(graph.Triggers)
+				gate.Dynamic = &dynamic
+			}
+			for i := range gate.Areas {
+				gate.Areas[i] += offset
+			}
+			graph.Gates = append(graph.Gates, gate)
+		}
+
+		graph.Triggers = append(graph.Triggers, floorGraph.Triggers...)
+
+		for _, link := range floorGraph.Links {
+			graph.Links = append(graph.Links, &AreaLink{From: link.From + offset, To: link.To + offset})
+		}
+
+		for _, bp := range floorGraph.BreakPoints {
+			bp.Floor = floorIdx
+			for i := range bp.AreaIDs {
+				bp.AreaIDs[i] += offset
+			}
+			graph.BreakPoints = append(graph.BreakPoints, bp)
+		}
+
+		// 中心飞只能落在同一层
+		for _, result := range floorGraph.centerFlyCache {
+			result.FromArea += offset
+			result.TargetAreas = make(map[int]*CenterFlyTarget)
+			for _, target := range result.Targets {
+				target.TargetArea += offset
+				result.TargetAreas[target.TargetArea] = target
+			}
+			graph.centerFlyCache[result.FromArea] = result
+		}
+
+		for i, row := range floorGraph.AreaMap {
+			for j, areaID := range row {
+				if areaID != -1 {
+					floorGraph.AreaMap[i][j] = areaID + offset
+				}
+			}
+		}
+		graph.FloorAreaMaps[floorIdx] = floorGraph.AreaMap
+
+		if floorGraph.StartArea != -1 {
+			graph.StartArea = floorGraph.StartArea + offset
+			graph.AreaMap = floorGraph.AreaMap
+			graph.centerPos = floorGraph.centerPos
+		}
+		if floorGraph.EndArea != -1 {
+			graph.EndArea = floorGraph.EndArea + offset
+		}
+
+		for i, row := range floor.GameMap {
+			for j, cellValue := range row {
+				switch cellValue {
+				case UpStairsID:
+					upStairs[floorIdx] = append(upStairs[floorIdx], floorGraph.AreaMap[i][j])
+					graph.StairAreas[floorIdx] = append(graph.StairAreas[floorIdx], floorGraph.AreaMap[i][j])
+				case DownStairsID:
+					downStairs[floorIdx] = append(downStairs[floorIdx], floorGraph.AreaMap[i][j])
+					graph.StairAreas[floorIdx] = append(graph.StairAreas[floorIdx], floorGraph.AreaMap[i][j])
+				}
+			}
+		}
+	}
+
+	// 楼梯双向连接相邻楼层
+	for floorIdx := 0; floorIdx+1 < len(tc.tower.Floors); floorIdx++ {
+		for _, upArea := range upStairs[floorIdx] {
+			for _, downArea := range downStairs[floorIdx+1] {
+				graph.Links = append(graph.Links,
+					&AreaLink{From: upArea, To: downArea},
+					&AreaLink{From: downArea, To: upArea})
+			}
+		}
+	}
+
+	graph.indexGates()
+	return graph, nil
+}
+
+// 获取每层可以使用楼传的楼梯区域（没有楼传器时返回nil）
+func (g *Graph) TeleportStairAreas() map[int][]int {
+	if g.Teleporter == nil {
+		return nil
+	}
+	stairs := make(map[int][]int)
+	for floor, areas := range g.StairAreas {
+		if g.Teleporter.allows(floor) && len(areas) > 0 {
+			stairs[floor] = areas
+		}
+	}
+	return stairs
+}
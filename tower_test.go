@@ -0,0 +1,96 @@
+package main
+
+import "testing"
+
+// 三层塔：钥匙在1层的怪物后面，0层的血瓶在黄门后面，终点在2层
+// 最优路线要先上1层打怪拿钥匙，再下回0层开门拿血瓶，最后上到2层
+func towerFixture() *Tower {
+	return &Tower{
+		Floors: []*Floor{
+			{GameMap: [][]int{
+				{1, 1, 1, 1, 1, 1},
+				{1, 31, YellowDoorID, 0, UpStairsID, 1},
+				{1, 1, 1, 1, 1, 1},
+			}},
+			{GameMap: [][]int{
+				{1, 1, 1, 1, 1, 1, 1},
+				{1, DownStairsID, 213, 0, 21, UpStairsID, 1},
+				{1, 1, 1, 1, 1, 1, 1},
+			}},
+			{GameMap: [][]int{
+				{1, 1, 1, 1},
+				{1, DownStairsID, 0, 1},
+				{1, 1, 1, 1},
+			}},
+		},
+		StartFloor: 0,
+		Start:      [2]int{1, 3},
+		EndFloor:   2,
+		End:        [2]int{1, 2},
+	}
+}
+
+// 区域带有所在楼层，楼梯双向连接相邻楼层的上下楼梯区域
+func TestTowerFloorsAndStairs(t *testing.T) {
+	graph, err := NewTowerToGraphConverter(towerFixture(), treasureMap, monsterMap, npcMap).Convert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start, end := graph.Areas[graph.StartArea], graph.Areas[graph.EndArea]; start.Floor != 0 || end.Floor != 2 {
+		t.Errorf("起点在%d层, 终点在%d层, 期望0层和2层", start.Floor, end.Floor)
+	}
+	areaAt := func(floor int, pos [2]int) int {
+		return graph.FloorAreaMaps[floor][pos[0]][pos[1]]
+	}
+	for _, c := range []struct {
+		floor int
+		pos   [2]int
+	}{
+		{0, [2]int{1, 1}}, {0, [2]int{1, 4}}, {1, [2]int{1, 1}}, {1, [2]int{1, 5}}, {2, [2]int{1, 1}},
+	} {
+		if area := graph.Areas[areaAt(c.floor, c.pos)]; area.Floor != c.floor {
+			t.Errorf("位置%v的区域在%d层, 期望%d层", c.pos, area.Floor, c.floor)
+		}
+	}
+
+	links := make(map[[2]int]bool)
+	for _, link := range graph.Links {
+		links[[2]int{link.From, link.To}] = true
+	}
+	for _, stairs := range [][2]int{
+		{areaAt(0, [2]int{1, 4}), areaAt(1, [2]int{1, 1})},
+		{areaAt(1, [2]int{1, 5}), areaAt(2, [2]int{1, 1})},
+	} {
+		if !links[stairs] || !links[[2]int{stairs[1], stairs[0]}] {
+			t.Errorf("楼梯区域%d和%d之间缺少双向连接", stairs[0], stairs[1])
+		}
+	}
+	if len(graph.Links) != 4 {
+		t.Errorf("连接数 = %d, 期望只有两组楼梯的4条", len(graph.Links))
+	}
+}
+
+// 跨楼层战斗和拾取：上楼拿到钥匙后要下回0层开门拿血瓶
+func TestTowerSearchGoesBackDown(t *testing.T) {
+	initDamageCache()
+	graph, err := NewTowerToGraphConverter(towerFixture(), treasureMap, monsterMap, npcMap).Convert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := findOptimalPathWithOptions(graph, &HeroItem{HP: 100, ATK: 10, DEF: 6, AreaID: graph.StartArea},
+		&HeroItem{AreaID: graph.EndArea}, &SearchOptions{Prune: &PruneConfig{Exact: true}})
+	want := 100 - getDamage(10, 6, 213) + int16(treasureMap[31].Value)
+	if result.HP != want || !result.Optimal {
+		t.Fatalf("HP=%d 最优=%v, 期望HP=%d", result.HP, result.Optimal, want)
+	}
+
+	var floors []int
+	for _, action := range result.Path {
+		if action.Type == actionFight {
+			floors = append(floors, action.Floor)
+		}
+	}
+	if len(floors) != 2 || floors[0] != 1 || floors[1] != 0 {
+		t.Errorf("战斗所在的楼层 = %v, 期望先在1层打怪再回0层开门", floors)
+	}
+}
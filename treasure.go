@@ -1,14 +1,27 @@
 package main
 
+import "fmt"
+
 type Treasure struct {
-	Type  int
-	Value int8 // 1 byte
+	Type     int
+	Value    int8       // 1 byte
+	Optional bool       // 可选拾取：不会自动获得，需要在搜索中显式选择是否拾取
+	Equip    *Equipment // 装备属性（仅treasureEquipment类型）
+}
+
+// 装备：穿戴在指定部位，提供攻防加成
+type Equipment struct {
+	Name string
+	Slot int
+	ATK  int8
+	DEF  int8
 }
 
 type TreasureItem struct {
 	ID         int
 	Type       int
 	Value      int8
+	Equip      *Equipment
 	OriginalID int
 }
 
@@ -17,28 +30,111 @@ type GlobalTreasure struct {
 	AreaID     int
 	Type       int
 	Value      int8
+	Equip      *Equipment
 	OriginalID int
 }
 
+// 图中所有区域的宝物，下标即收集状态的位索引
+func (g *Graph) globalTreasures() []*GlobalTreasure {
+	allTreasures := []*GlobalTreasure{}
+	for _, area := range g.Areas {
+		for idx, treasure := range area.Treasures {
+			allTreasures = append(allTreasures, &GlobalTreasure{
+				ID:         fmt.Sprintf("%d-%d", area.ID, idx),
+				AreaID:     area.ID,
+				Type:       treasure.Type,
+				Value:      treasure.Value,
+				Equip:      treasure.Equip,
+				OriginalID: treasure.OriginalID,
+			})
+		}
+	}
+	return allTreasures
+}
+
+// 药水解除的状态异常
+var cureAilments = map[int]uint8{
+	treasureCurePoison: ailmentPoison,
+	treasureCureWeak:   ailmentWeak,
+	treasureCureCurse:  ailmentCurse,
+}
+
+// 应用宝物中的解药
+func applyCures(allTreasures []*GlobalTreasure, ailments uint8, treasureIndices []int) uint8 {
+	for _, idx := range treasureIndices {
+		ailments &^= cureAilments[allTreasures[idx].Type]
+	}
+	return ailments
+}
+
+// 比较装备优劣：攻防加成之和更高的装备更好
+func (e *Equipment) betterThan(other *Equipment) bool {
+	if other == nil {
+		return true
+	}
+	return e.ATK+e.DEF > other.ATK+other.DEF
+}
+
+// 穿戴装备：只有比当前同部位装备更好时才替换
+func equip(equipped [equipSlotCount]*Equipment, item *Equipment) [equipSlotCount]*Equipment {
+	if item != nil && item.betterThan(equipped[item.Slot]) {
+		equipped[item.Slot] = item
+	}
+	return equipped
+}
+
+// 应用宝物中的装备
+func applyEquipment(allTreasures []*GlobalTreasure, equipped [equipSlotCount]*Equipment, treasureIndices []int) [equipSlotCount]*Equipment {
+	for _, idx := range treasureIndices {
+		equipped = equip(equipped, allTreasures[idx].Equip)
+	}
+	return equipped
+}
+
+// 计算装备提供的总加成
+func equipmentBonus(equipped [equipSlotCount]*Equipment) (int8, int8) {
+	var atk, def int
+	for _, item := range equipped {
+		if item != nil {
+			atk += int(item.ATK)
+			def += int(item.DEF)
+		}
+	}
+	return clampInt8(atk), clampInt8(def)
+}
+
+// 应用单个宝物效果（超出字段的范围时取边界值）
+func applyTreasureEffect(treasureType int, value int8, hp int16, mdef uint8, atk, def int8, yellowKey, blueKey int16) (int16, uint8, int8, int8, int16, int16) {
+	switch treasureType {
+	case treasureDEF:
+		def = clampInt8(int(def) + int(value))
+	case treasureATK:
+		atk = clampInt8(int(atk) + int(value))
+	case treasureHP:
+		hp = clampInt16(int(hp) + int(value))
+	case treasureYellowKey:
+		yellowKey = clampInt16(int(yellowKey) + int(value))
+	case treasureBlueKey:
+		blueKey = clampInt16(int(blueKey) + int(value))
+	case treasureMDEF:
+		mdef = clampUint8(int(mdef) + int(value))
+	}
+	return hp, mdef, atk, def, yellowKey, blueKey
+}
+
 // 应用宝物效果（更新支持蓝钥匙）
-func applyTreasures(allTreasures []*GlobalTreasure, hp int16, mdef uint8, atk, def, yellowKey, blueKey int8, treasureIndices []int) (int16, uint8, int8, int8, int8, int8) {
-	newHP, newATK, newDEF, newMDEF, newYellowKeys, newBlueKeys := hp, atk, def, mdef, yellowKey, blueKey
+func applyTreasures(allTreasures []*GlobalTreasure, hp int16, mdef uint8, atk, def int8, yellowKey, blueKey int16, treasureIndices []int) (int16, uint8, int8, int8, int16, int16) {
 	for _, idx := range treasureIndices {
 		treasure := allTreasures[idx]
-		switch treasure.Type {
-		case treasureDEF:
-			newDEF += treasure.Value
-		case treasureATK:
-			newATK += treasure.Value
-		case treasureHP:
-			newHP += int16(treasure.Value)
-		case treasureYellowKey:
-			newYellowKeys += treasure.Value
-		case treasureBlueKey:
-			newBlueKeys += treasure.Value
-		case treasureMDEF:
-			newMDEF += uint8(treasure.Value)
-		}
+		hp, mdef, atk, def, yellowKey, blueKey = applyTreasureEffect(treasure.Type, treasure.Value, hp, mdef, atk, def, yellowKey, blueKey)
+	}
+	return hp, mdef, atk, def, yellowKey, blueKey
+}
+
+// 装备a是否在攻击和防御上都不比装备b差（nil表示没有装备）
+func equipmentCovers(a, b *Equipment) bool {
+	if b == nil {
+		return true
 	}
-	return newHP, newMDEF, newATK, newDEF, newYellowKeys, newBlueKeys
+	return a != nil && a.ATK >= b.ATK && a.DEF >= b.DEF
 }
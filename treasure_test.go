@@ -0,0 +1,172 @@
+package main
+
+import "testing"
+
+// 在地图上精确搜索，hero和required的区域取起点和终点所在的区域
+func searchMap(t *testing.T, m [][]int, start, end [2]int, hero, required HeroItem) SearchResult {
+	t.Helper()
+	initDamageCache()
+	graph := convertForTest(t, m, start, end)
+	hero.AreaID, required.AreaID = graph.StartArea, graph.EndArea
+	return findOptimalPathWithOptions(graph, &hero, &required, &SearchOptions{Prune: &PruneConfig{Exact: true}})
+}
+
+// 路径中某种动作的次数
+func countActions(path []Action, actionType int8) int {
+	count := 0
+	for _, action := range path {
+		if action.Type == actionType {
+			count++
+		}
+	}
+	return count
+}
+
+// 有害的可选物品不拾取，有益的可选物品拾取
+func TestOptionalItemPickup(t *testing.T) {
+	for _, c := range []struct {
+		item   int
+		hp     int16
+		picked int
+	}{
+		{33, 400, 0}, // 诅咒物品：拾取会扣血
+		{32, 500, 1}, // 大血瓶
+	} {
+		m := [][]int{
+			{1, 1, 1, 1, 1},
+			{1, 0, 0, 0, 1},
+			{1, 1, c.item, 1, 1},
+			{1, 1, 1, 1, 1},
+		}
+		result := searchMap(t, m, [2]int{1, 1}, [2]int{1, 3}, HeroItem{HP: 400, ATK: 10, DEF: 6}, HeroItem{})
+		if result.HP != c.hp || countActions(result.Path, actionPickItem) != c.picked {
+			t.Errorf("物品%d: HP=%d 拾取%d次, 期望HP=%d 拾取%d次", c.item, result.HP,
+				countActions(result.Path, actionPickItem), c.hp, c.picked)
+		}
+	}
+}
+
+// 更好的剑替换当前的剑，更差的剑不替换，攻击力不叠加
+func TestEquipmentReplaces(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		first, last int // 起点处的剑和打过怪物后拿到的剑
+	}{
+		{"先拿铁剑再拿银剑", 41, 42},
+		{"先拿银剑再拿铁剑", 42, 41},
+	} {
+		m := [][]int{
+			{1, 1, 1, 1, 1, 1, 1},
+			{1, 0, c.first, 0, 213, c.last, 1},
+			{1, 1, 1, 1, 1, 1, 1},
+		}
+		result := searchMap(t, m, [2]int{1, 1}, [2]int{1, 5}, HeroItem{HP: 400, ATK: 10, DEF: 6}, HeroItem{})
+		weapon := result.Equipped[slotWeapon]
+		if weapon == nil || weapon.Name != "银剑" || result.ATK != 14 {
+			t.Errorf("%s: 武器=%v 攻击=%d, 期望银剑 攻击14", c.name, weapon, result.ATK)
+		}
+	}
+}
+
+// 毒蝙蝠后面有两个怪物：中毒时每场战斗扣10血，拾取解毒药水只扣一次
+func TestPoisonCuredByItem(t *testing.T) {
+	corridor := func(cure int) [][]int {
+		return [][]int{
+			{1, 1, 1, 1, 1, 1, 1, 1, 1},
+			{1, 0, 215, 0, 210, 0, 210, 0, 1},
+			{1, 1, 1, cure, 1, 1, 1, 1, 1},
+			{1, 1, 1, 1, 1, 1, 1, 1, 1},
+		}
+	}
+	hero := HeroItem{HP: 400, ATK: 10, DEF: 6}
+	poisoned := searchMap(t, corridor(1), [2]int{1, 1}, [2]int{1, 7}, hero, HeroItem{})
+	if poisoned.Ailments&ailmentPoison == 0 {
+		t.Fatalf("没有解毒药水时状态异常 = %d, 期望中毒", poisoned.Ailments)
+	}
+	cured := searchMap(t, corridor(51), [2]int{1, 1}, [2]int{1, 7}, hero, HeroItem{})
+	if cured.Ailments != 0 || countActions(cured.Path, actionPickItem) != 1 || cured.HP != poisoned.HP+poisonDamagePerAction {
+		t.Errorf("有解毒药水时 HP=%d 状态异常=%d, 期望拾取药水后 HP=%d 且不再中毒",
+			cured.HP, cured.Ailments, poisoned.HP+poisonDamagePerAction)
+	}
+}
+
+// 衰弱时攻击力不够要求，只有拾取解衰药水后才能到达终点
+func TestWeaknessCuredByItem(t *testing.T) {
+	corridor := func(cure int) [][]int {
+		return [][]int{
+			{1, 1, 1, 1, 1, 1, 1},
+			{1, 0, 216, 0, 0, 0, 1},
+			{1, 1, 1, cure, 1, 1, 1},
+			{1, 1, 1, 1, 1, 1, 1},
+		}
+	}
+	hero, required := HeroItem{HP: 400, ATK: 10, DEF: 6}, HeroItem{ATK: 10}
+	if weak := searchMap(t, corridor(1), [2]int{1, 1}, [2]int{1, 5}, hero, required); weak.HP != -1 {
+		t.Errorf("没有解衰药水时 HP=%d 攻击=%d, 期望衰弱后攻击不够要求", weak.HP, weak.ATK)
+	}
+	cured := searchMap(t, corridor(52), [2]int{1, 1}, [2]int{1, 5}, hero, required)
+	if cured.HP <= 0 || cured.Ailments != 0 || cured.ATK != 10 {
+		t.Errorf("有解衰药水时 HP=%d 攻击=%d 状态异常=%d, 期望解除衰弱", cured.HP, cured.ATK, cured.Ailments)
+	}
+}
+
+// 诅咒后战斗不再获得金币，拾取解咒药水后恢复
+func TestCurseCuredByItem(t *testing.T) {
+	corridor := func(cure int) [][]int {
+		return [][]int{
+			{1, 1, 1, 1, 1, 1, 1},
+			{1, 0, 217, 0, 213, 0, 1},
+			{1, 1, 1, cure, 1, 1, 1},
+			{1, 1, 1, 1, 1, 1, 1},
+		}
+	}
+	hero := HeroItem{HP: 1000, ATK: 20, DEF: 10}
+	cursed := searchMap(t, corridor(1), [2]int{1, 1}, [2]int{1, 5}, hero, HeroItem{})
+	if cursed.Ailments&ailmentCurse == 0 || cursed.Money != int32(monsterMap[217].Money) {
+		t.Errorf("没有解咒药水时 金币=%d 状态异常=%d, 期望只拿到诅咒骷髅的金币", cursed.Money, cursed.Ailments)
+	}
+	cured := searchMap(t, corridor(54), [2]int{1, 1}, [2]int{1, 5}, hero, HeroItem{})
+	if want := int32(monsterMap[217].Money) + int32(monsterMap[213].Money); cured.Ailments != 0 || cured.Money != want {
+		t.Errorf("有解咒药水时 金币=%d 状态异常=%d, 期望%d金币且不再诅咒", cured.Money, cured.Ailments, want)
+	}
+}
+
+// 能到达商店时花金币解毒
+func TestPoisonCuredByShop(t *testing.T) {
+	defer func(positions [][3]int) { cureShopPositions = positions }(cureShopPositions)
+	m := [][]int{
+		{1, 1, 1, 1, 1, 1, 1, 1, 1},
+		{1, 0, 215, 0, 210, 0, 210, 0, 1},
+		{1, 1, 1, 1, 1, 1, 1, 1, 1},
+	}
+	hero := HeroItem{HP: 400, ATK: 10, DEF: 6, Money: cureShopPrices[ailmentPoison]}
+	cureShopPositions = nil
+	poisoned := searchMap(t, m, [2]int{1, 1}, [2]int{1, 7}, hero, HeroItem{})
+	cureShopPositions = [][3]int{{0, 1, 1}}
+	cured := searchMap(t, m, [2]int{1, 1}, [2]int{1, 7}, hero, HeroItem{})
+	if poisoned.Ailments&ailmentPoison == 0 || countActions(poisoned.Path, actionCure) != 0 {
+		t.Errorf("没有商店时状态异常 = %d, 期望中毒", poisoned.Ailments)
+	}
+	if cured.Ailments != 0 || countActions(cured.Path, actionCure) != 1 || cured.HP != poisoned.HP+2*poisonDamagePerAction ||
+		cured.Money != poisoned.Money-cureShopPrices[ailmentPoison] {
+		t.Errorf("有商店时 HP=%d 金币=%d 状态异常=%d, 期望解毒后 HP=%d 金币=%d", cured.HP, cured.Money, cured.Ailments,
+			poisoned.HP+2*poisonDamagePerAction, poisoned.Money-cureShopPrices[ailmentPoison])
+	}
+}
+
+// 攻击力打不动怪物时，拾取可选的魔法药水后才能使用二倍斩
+func TestOptionalManaEnablesSkill(t *testing.T) {
+	m := [][]int{
+		{1, 1, 1, 1, 1},
+		{1, 0, 214, 0, 1},
+		{1, 55, 1, 1, 1},
+		{1, 1, 1, 1, 1},
+	}
+	result := searchMap(t, m, [2]int{1, 1}, [2]int{1, 3}, HeroItem{HP: 400, ATK: 10, DEF: 6}, HeroItem{})
+	if result.HP <= 0 || countActions(result.Path, actionPickItem) != 1 {
+		t.Fatalf("HP=%d 拾取%d次, 期望拾取魔法药水后打过怪物", result.HP, countActions(result.Path, actionPickItem))
+	}
+	if last := result.Path[len(result.Path)-1]; last.Type != actionFight || last.Skill < 0 || result.MP != 10-skillList[last.Skill].MPCost {
+		t.Errorf("最后一步 = %+v, 剩余MP=%d, 期望使用技能战斗", last, result.MP)
+	}
+}
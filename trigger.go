@@ -0,0 +1,110 @@
+package main
+
+import "fmt"
+
+// 事件触发器：满足条件后改变地图上的格子
+// 机关门：守卫全部被击败后打开；陷阱：踩到机关格后身后的通道变为墙
+type Trigger struct {
+	Floor  int      // 所在楼层（由多层塔转换器设置）
+	Guards [][2]int // 条件：这些位置的怪物全部被击败
+	Tiles  [][2]int // 条件：这些格子全部被踩过
+	Open   [][2]int // 效果：打开的机关门
+	Close  [][2]int // 效果：变为墙的格子
+}
+
+// 动态格子的类型
+const (
+	dynamicDoor  = int8(iota + 1) // 机关门：触发前不能通过
+	dynamicTile                   // 机关格：踩上去会被记录
+	dynamicClose                  // 会被封闭的通道：触发前可以通过
+)
+
+// 动态格子：状态由触发器决定，转换时和怪物一样作为连接点处理
+type DynamicCell struct {
+	Kind    int8
+	Trigger int // 所属触发器的编号
+}
+
+// 收集触发器涉及的动态格子（守卫本身就是连接点，不需要记录）
+func dynamicCellsOf(triggers []*Trigger) map[[2]int]*DynamicCell {
+	cells := make(map[[2]int]*DynamicCell)
+	for triggerIdx, trigger := range triggers {
+		for _, pos := range trigger.Tiles {
+			cells[pos] = &DynamicCell{Kind: dynamicTile, Trigger: triggerIdx}
+		}
+		for _, pos := range trigger.Open {
+			cells[pos] = &DynamicCell{Kind: dynamicDoor, Trigger: triggerIdx}
+		}
+		for _, pos := range trigger.Close {
+			cells[pos] = &DynamicCell{Kind: dynamicClose, Trigger: triggerIdx}
+		}
+	}
+	return cells
+}
+
+// 检查触发器的条件和涉及的位置：找不到连接点的守卫或机关格会让条件变空，机关门一开始就会打开
+// isGate判断触发器所在楼层的某个位置是否为连接点
+func checkTriggers(triggers []*Trigger, isGate func(trigger *Trigger, pos [2]int) bool) error {
+	for i, trigger := range triggers {
+		if len(trigger.Guards) == 0 && len(trigger.Tiles) == 0 {
+			return fmt.Errorf("触发器%d没有条件", i)
+		}
+		for _, positions := range [][][2]int{trigger.Guards, trigger.Tiles, trigger.Open, trigger.Close} {
+			for _, pos := range positions {
+				if !isGate(trigger, pos) {
+					return fmt.Errorf("触发器%d的位置%v不是能到达的连接点", i, pos)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// 触发器对应的连接点位掩码
+type triggerMask struct {
+	condition *ExtendedBitSet // 需要全部击败（或踩过）的连接点
+	open      *ExtendedBitSet // 触发后打开的连接点
+	close     *ExtendedBitSet // 触发后封闭的连接点
+}
+
+// 预计算触发器涉及的连接点，连接点按楼层和位置匹配
+func buildTriggerMasks(graph *Graph) []triggerMask {
+	maskOf := func(floor int, positions ...[][2]int) *ExtendedBitSet {
+		mask := NewExtendedBitSet(len(graph.Gates))
+		for _, group := range positions {
+			for _, pos := range group {
+				if gate := graph.GateAt(floor, pos); gate != nil {
+					mask.Set(gate.ID)
+				}
+			}
+		}
+		return mask
+	}
+
+	masks := make([]triggerMask, len(graph.Triggers))
+	for i, trigger := range graph.Triggers {
+		masks[i] = triggerMask{
+			condition: maskOf(trigger.Floor, trigger.Guards, trigger.Tiles),
+			open:      maskOf(trigger.Floor, trigger.Open),
+			close:     maskOf(trigger.Floor, trigger.Close),
+		}
+	}
+	return masks
+}
+
+// 应用所有条件已满足的触发器：打开的机关门视为已通过，封闭的通道视为未通过
+// 一个触发器打开的门可能满足另一个触发器的条件，反复应用直到没有新的触发器生效（每个触发器只生效一次）
+// 返回新的位图，原位图不变
+func applyTriggers(masks []triggerMask, defeatedMonsters *ExtendedBitSet) *ExtendedBitSet {
+	fired := make([]bool, len(masks))
+	for changed := true; changed; {
+		changed = false
+		for i, mask := range masks {
+			if !fired[i] && defeatedMonsters.ContainsAll(mask.condition) {
+				defeatedMonsters = defeatedMonsters.Union(mask.open).Difference(mask.close)
+				fired[i], changed = true, true
+			}
+		}
+	}
+	return defeatedMonsters
+}
@@ -0,0 +1,101 @@
+package main
+
+import "testing"
+
+// 一个触发器打开的门满足另一个触发器的条件，与触发器的顺序无关
+func TestApplyTriggersFixpoint(t *testing.T) {
+	mask := func(ids ...int) *ExtendedBitSet {
+		bits := NewExtendedBitSet(8)
+		for _, id := range ids {
+			bits.Set(id)
+		}
+		return bits
+	}
+	first := triggerMask{condition: mask(0), open: mask(1), close: mask()}
+	second := triggerMask{condition: mask(1), open: mask(2), close: mask()}
+	for _, masks := range [][]triggerMask{{first, second}, {second, first}} {
+		if result := applyTriggers(masks, mask(0)); !result.IsSet(1) || !result.IsSet(2) {
+			t.Error("两个触发器都应该生效")
+		}
+	}
+
+	// 互相打开和封闭同一个格子时，每个触发器只生效一次
+	open := triggerMask{condition: mask(0), open: mask(3), close: mask()}
+	closeAgain := triggerMask{condition: mask(3), open: mask(), close: mask(3)}
+	if result := applyTriggers([]triggerMask{open, closeAgain}, mask(0)); result.IsSet(3) {
+		t.Error("格子应该先打开再被封闭")
+	}
+}
+
+// 两扇机关门：打败怪物打开第一扇，第一扇打开后再打开第二扇
+func TestTriggerChainSearch(t *testing.T) {
+	initDamageCache()
+	m := [][]int{
+		{1, 1, 1, 1, 1, 1, 1, 1, 1},
+		{1, 0, 210, 0, MechanismDoorID, 0, MechanismDoorID, 0, 1},
+		{1, 1, 1, 1, 1, 1, 1, 1, 1},
+	}
+	first := &Trigger{Guards: [][2]int{{1, 2}}, Open: [][2]int{{1, 4}}}
+	second := &Trigger{Guards: [][2]int{{1, 4}}, Open: [][2]int{{1, 6}}}
+	converter := NewMapToGraphConverter(m, treasureMap, monsterMap, npcMap, [2]int{1, 1}, [2]int{1, 7})
+	converter.SetTriggers([]*Trigger{second, first})
+	graph, err := converter.Convert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := findOptimalPath(graph, &HeroItem{HP: 400, ATK: 10, DEF: 6, AreaID: graph.StartArea}, &HeroItem{AreaID: graph.EndArea})
+	if result.HP != 400-getDamage(10, 6, 210) {
+		t.Errorf("HP = %d, 期望打过怪物后两扇门依次打开", result.HP)
+	}
+}
+
+// 触发器的位置不是连接点或没有条件时转换失败
+func TestInvalidTriggers(t *testing.T) {
+	m := [][]int{
+		{1, 1, 1, 1, 1, 1, 1},
+		{1, 0, 210, 0, MechanismDoorID, 0, 1},
+		{1, 1, 1, 1, 1, 1, 1},
+	}
+	cases := []struct {
+		name    string
+		trigger *Trigger
+	}{
+		{"守卫不是怪物", &Trigger{Guards: [][2]int{{1, 3}}, Open: [][2]int{{1, 4}}}},
+		{"机关门在墙里", &Trigger{Guards: [][2]int{{1, 2}}, Open: [][2]int{{0, 4}}}},
+		{"没有条件", &Trigger{Open: [][2]int{{1, 4}}}},
+	}
+	for _, c := range cases {
+		converter := NewMapToGraphConverter(m, treasureMap, monsterMap, npcMap, [2]int{1, 1}, [2]int{1, 5})
+		converter.SetTriggers([]*Trigger{c.trigger})
+		if _, err := converter.Convert(); err == nil {
+			t.Errorf("%s: 期望转换失败", c.name)
+		}
+	}
+}
+
+// 多层塔转换不修改调用者的触发器，触发器编号在楼层间统一
+func TestTowerTriggersNotMutated(t *testing.T) {
+	m := [][]int{
+		{1, 1, 1, 1, 1, 1, 1},
+		{1, 0, 210, 0, MechanismDoorID, 0, 1},
+		{1, 1, 1, 1, 1, 1, 1},
+	}
+	trigger := &Trigger{Guards: [][2]int{{1, 2}}, Open: [][2]int{{1, 4}}}
+	tower := &Tower{Floors: []*Floor{{GameMap: m, Triggers: []*Trigger{trigger}}, {GameMap: m, Triggers: []*Trigger{trigger}}}}
+	graph, err := NewTowerToGraphConverter(tower, treasureMap, monsterMap, npcMap).Convert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trigger.Floor != 0 {
+		t.Errorf("调用者的触发器楼层被改为%d", trigger.Floor)
+	}
+	if len(graph.Triggers) != 2 || graph.Triggers[0].Floor != 0 || graph.Triggers[1].Floor != 1 {
+		t.Fatalf("触发器 = %+v, 期望每层各一个", graph.Triggers)
+	}
+	for floor := 0; floor < 2; floor++ {
+		door := graph.GateAt(floor, [2]int{1, 4})
+		if door == nil || door.Dynamic == nil || door.Dynamic.Trigger != floor {
+			t.Errorf("第%d层的机关门 = %+v, 期望属于触发器%d", floor, door, floor)
+		}
+	}
+}
@@ -139,3 +139,122 @@ func setBitExt(bitset *ExtendedBitSet, pos int) *ExtendedBitSet {
 	newBitset.Set(pos)
 	return newBitset
 }
+
+// Clear 清除指定位
+func (ebs *ExtendedBitSet) Clear(pos int) {
+	if pos < 0 || pos/64 >= len(ebs.bits) {
+		return
+	}
+	ebs.bits[pos/64] &^= 1 << (pos % 64)
+}
+
+// ContainsAll 是否包含other中设置的所有位
+func (ebs *ExtendedBitSet) ContainsAll(other *ExtendedBitSet) bool {
+	for i, word := range other.bits {
+		var own uint64
+		if i < len(ebs.bits) {
+			own = ebs.bits[i]
+		}
+		if own&word != word {
+			return false
+		}
+	}
+	return true
+}
+
+// Union 返回两个位图的并集（原位图不变）
+func (ebs *ExtendedBitSet) Union(other *ExtendedBitSet) *ExtendedBitSet {
+	result := ebs.Copy()
+	for len(result.bits) < len(other.bits) {
+		result.bits = append(result.bits, 0)
+		result.size++
+	}
+	for i, word := range other.bits {
+		result.bits[i] |= word
+	}
+	return result
+}
+
+// Difference 返回去掉other中设置的位之后的位图（原位图不变）
+func (ebs *ExtendedBitSet) Difference(other *ExtendedBitSet) *ExtendedBitSet {
+	result := ebs.Copy()
+	for i := 0; i < len(result.bits) && i < len(other.bits); i++ {
+		result.bits[i] &^= other.bits[i]
+	}
+	return result
+}
+
+// 去掉末尾全零字后的有效字数
+func (ebs *ExtendedBitSet) usedWords() int {
+	n := len(ebs.bits)
+	for n > 0 && ebs.bits[n-1] == 0 {
+		n--
+	}
+	return n
+}
+
+// Key 无损的可比较key：只用到前64位时wide为空，否则wide为其余字的字节编码
+func (ebs *ExtendedBitSet) Key() (low uint64, wide string) {
+	n := ebs.usedWords()
+	if n == 0 {
+		return 0, ""
+	}
+	if n == 1 {
+		return ebs.bits[0], ""
+	}
+	return ebs.bits[0], string(appendWords(nil, ebs.bits[1:n]))
+}
+
+// 按小端字节序追加若干64位字
+func appendWords(buf []byte, words []uint64) []byte {
+	for _, word := range words {
+		for shift := 0; shift < 64; shift += 8 {
+			buf = append(buf, byte(word>>shift))
+		}
+	}
+	return buf
+}
+
+// 截断到int8范围
+func clampInt8(value int) int8 {
+	if value > 127 {
+		return 127
+	}
+	if value < -128 {
+		return -128
+	}
+	return int8(value)
+}
+
+// 截断到uint8范围
+func clampUint8(value int) uint8 {
+	if value > 255 {
+		return 255
+	}
+	if value < 0 {
+		return 0
+	}
+	return uint8(value)
+}
+
+// 截断到int16范围
+func clampInt16(value int) int16 {
+	if value > 32767 {
+		return 32767
+	}
+	if value < -32768 {
+		return -32768
+	}
+	return int16(value)
+}
+
+// 截断到int32范围
+func clampInt32(value int) int32 {
+	if value > 2147483647 {
+		return 2147483647
+	}
+	if value < -2147483648 {
+		return -2147483648
+	}
+	return int32(value)
+}